@@ -3,7 +3,10 @@ package hyb
 import (
 	"bytes"
 	"encoding/gob"
+	"math"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/robskie/bp128"
 )
@@ -18,31 +21,24 @@ const (
 )
 
 type doc struct {
-	id    int
-	words []string
-	rank  int
+	id      int
+	words   []string
+	phrases []string
+	rank    int
 
 	count   int
 	deleted bool
 }
 
-type byRank []doc
-
-func (d byRank) Len() int           { return len(d) }
-func (d byRank) Less(i, j int) bool { return d[i].rank < d[j].rank }
-func (d byRank) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
-
-type byID []doc
-
-func (d byID) Len() int { return len(d) }
-func (d byID) Less(i, j int) bool {
-	if d[i].id != d[j].id {
-		return d[i].id < d[j].id
+// byID returns true if a comes before b when
+// sorted by ascending id and descending counter.
+func byID(a, b doc) bool {
+	if a.id != b.id {
+		return a.id < b.id
 	}
 
-	return d[i].count > d[j].count
+	return a.count > b.count
 }
-func (d byID) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
 
 type word struct {
 	id   int
@@ -55,20 +51,18 @@ func (w byWord) Len() int           { return len(w) }
 func (w byWord) Less(i, j int) bool { return w[i].id < w[j].id }
 func (w byWord) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
 
-type byFrequency []*word
-
-func (w byFrequency) Len() int           { return len(w) }
-func (w byFrequency) Less(i, j int) bool { return w[i].freq > w[j].freq }
-func (w byFrequency) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
+// byFrequency returns true if a comes before b when
+// sorted by descending frequency. Ties are broken by
+// ascending id.
+func byFrequency(a, b *word) bool {
+	if a.freq != b.freq {
+		return a.freq > b.freq
+	}
 
-type bposting struct {
-	id   int
-	word *string
-	rank int
+	return a.id < b.id
 }
 
 type block struct {
-	posts    []*bposting
 	boundary [2]int
 
 	index  int
@@ -158,75 +152,225 @@ func (b *pblock) GobDecode(data []byte) error {
 type Builder struct {
 	docs  []doc
 	count int
+
+	phraseLen     int
+	phraseMinDocs int
+
+	queries     map[string]int
+	queryWeight float64
+
+	// memLimit is the approximate number of bytes
+	// the added docs can use before they are spilled
+	// to a temporary file in tmpDir. Zero disables
+	// spilling.
+	memLimit int
+	memUsed  int
+	tmpDir   string
+	runs     []string
+
+	workers int
+	opts    BuilderOptions
+	cache   *CacheOptions
+	metrics Metrics
+
+	// topLen and topK are the maximum length of
+	// the precomputed prefixes and the number of
+	// hits and completions stored for each.
+	topLen int
+	topK   int
+
+	err error
 }
 
 // NewBuilder creates an empty builder.
 func NewBuilder() *Builder {
-	return &Builder{[]doc{}, 0}
+	return &Builder{
+		docs:        []doc{},
+		queries:     map[string]int{},
+		queryWeight: 0.5,
+	}
+}
+
+// EnablePhrases makes the builder record the word n-grams
+// of the documents added after this call, from bigrams up
+// to n-grams, so that the index can suggest phrase
+// completions. Phrases that are contained in fewer than
+// minDocs documents are discarded.
+func (b *Builder) EnablePhrases(n, minDocs int) {
+	b.phraseLen = n
+	b.phraseMinDocs = minDocs
 }
 
 // Add adds a document given its ID, search keywords, and rank.
+// If phrases are enabled, the order of the keywords determines
+// the phrases of the document.
 func (b *Builder) Add(id int, keywords []string, rank int) {
+	var phrases []string
+	if b.phraseLen > 1 {
+		phrases = getPhrases(keywords, b.phraseLen)
+		sort.Strings(phrases)
+	}
 	sort.Strings(keywords)
 
-	d := doc{id, keywords, rank, b.count, false}
-	b.docs = append(b.docs, d)
-
-	b.count++
+	b.add(doc{id, keywords, phrases, rank, b.count, false})
 }
 
 // Delete removes a document given its ID.
 func (b *Builder) Delete(id int) {
-	b.docs = append(b.docs, doc{id, nil, -1, b.count, true})
+	b.add(doc{id, nil, nil, -1, b.count, true})
+}
+
+func (b *Builder) add(d doc) {
+	b.docs = append(b.docs, d)
 	b.count++
+
+	if b.memLimit > 0 {
+		b.memUsed += d.memSize()
+		if b.memUsed >= b.memLimit {
+			b.spill()
+		}
+	}
+}
+
+// Err returns the first error encountered while
+// spilling documents to or reading them back from
+// temporary files. If it is not nil, Build returns nil.
+func (b *Builder) Err() error {
+	return b.err
 }
 
-// Build creates an index.
+// Build creates an index. If the builder has spilled
+// documents to temporary files, Build consumes those
+// files and removes them afterwards. Build is
+// deterministic: the index only depends on the added
+// documents and queries and on the options, and not on
+// the number of workers or the memory limit. Unless the
+// tie-break policy is InsertionOrder, it also does not
+// depend on the order in which documents with different
+// IDs were added. See Index.Hash.
 func (b *Builder) Build() *Index {
-	// Normalize ranks
-	sort.Sort(byRank(b.docs))
-	for i := range b.docs {
-		b.docs[i].rank = i
-	}
-
-	// Sort by ascending ids
-	// and descending counter
-	sort.Sort(byID(b.docs))
-
-	// Remove duplicates and deleted
-	// docs and create postings. Note:
-	// Since docs are already sorted,
-	// this results in sorted postings.
-	pid := -1
-	docs := b.docs[:0]
-	posts := []bposting{}
-	wordmap := map[string]*word{}
-	for _, d := range b.docs {
-		if pid != d.id && !d.deleted {
-			// Create postings
-			for j := range d.words {
-				w := d.words[j]
-
-				if wf := wordmap[w]; wf != nil {
-					wf.freq++
-				} else {
-					wordmap[w] = &word{-1, 1}
-				}
-
-				p := bposting{d.id, &d.words[j], d.rank}
-				posts = append(posts, p)
+	s := b.collect()
+	if s == nil {
+		return nil
+	}
+	defer b.close(s)
+
+	// Normalize ranks and blend
+	// query log popularity into them
+	workers := b.numWorkers()
+	ties := b.opts.TieBreak
+	normalizeRanks(s.ranks, s.counts, ties, workers)
+
+	var wordpop map[string]int
+	if s.queries != nil {
+		blendRanks(s.ranks, s.docpop, b.queryWeight, s.counts, ties, workers)
+		wordpop = s.queries.wordPopularity()
+	}
+
+	return b.finish(s, wordpop)
+}
+
+// buildState contains what the first pass
+// of Build collects from the documents.
+type buildState struct {
+	start  time.Time
+	stream docStream
+
+	// The IDs, ranks, insertion counters and query
+	// log popularity of the docs in ID order. The
+	// counters are only collected for InsertionOrder.
+	ids    []int
+	ranks  []int
+	counts []int
+	docpop []int
+
+	wordmap map[string]*word
+	phrases *phraseBuilder
+	queries *queryMatcher
+}
+
+// collect opens the document stream and collects the word
+// frequencies, ranks, phrases and query log popularity of
+// the documents. It returns nil if there is an error.
+func (b *Builder) collect() *buildState {
+	if b.err != nil {
+		b.Discard()
+		return nil
+	}
+
+	s := &buildState{
+		start:   time.Now(),
+		stream:  b.stream(),
+		wordmap: map[string]*word{},
+		phrases: newPhraseBuilder(b.phraseMinDocs),
+	}
+
+	if b.err != nil {
+		b.close(s)
+		return nil
+	}
+
+	if len(b.queries) > 0 {
+		s.queries = newQueryMatcher(b.queries)
+	}
+
+	// Note: Since docs are sorted by ID, the
+	// ith doc of each pass is the same.
+	for d, ok := s.stream.next(); ok; d, ok = s.stream.next() {
+		for _, w := range d.words {
+			if wf := s.wordmap[w]; wf != nil {
+				wf.freq++
+			} else {
+				s.wordmap[w] = &word{-1, 1}
 			}
-			docs = append(docs, d)
 		}
-		pid = d.id
+
+		s.ids = append(s.ids, d.id)
+		s.ranks = append(s.ranks, d.rank)
+		if b.opts.TieBreak == InsertionOrder {
+			s.counts = append(s.counts, d.count)
+		}
+		s.phrases.count(d)
+		if s.queries != nil {
+			s.docpop = append(s.docpop, s.queries.match(d))
+		}
+	}
+
+	if err := s.stream.err(); err != nil {
+		b.err = err
+		b.close(s)
+		return nil
+	}
+
+	return s
+}
+
+// close closes the document stream of a build.
+func (b *Builder) close(s *buildState) {
+	if err := s.stream.close(); err != nil && b.err == nil {
+		b.err = err
 	}
-	b.docs = docs
+}
+
+// finish creates the index from the collected documents
+// given their normalized ranks and the query log
+// popularity of the words, which is nil if there is
+// no query log.
+func (b *Builder) finish(s *buildState, wordpop map[string]int) *Index {
+	workers := b.numWorkers()
+	stream, ranks := s.stream, s.ranks
+	wordmap, phrases := s.wordmap, s.phrases
 
 	// Return empty index if no postings
-	if len(posts) == 0 {
+	if len(wordmap) == 0 {
 		return &Index{}
 	}
 
+	maxRank := 0
+	for _, r := range ranks {
+		maxRank = max(maxRank, r)
+	}
+
 	// Create words array and sort in lexicographical order
 	nchars := 0
 	wordcount := 0
@@ -237,7 +381,7 @@ func (b *Builder) Build() *Index {
 		nchars += len(word)
 		wordcount += wf.freq
 	}
-	sort.Strings(words)
+	parallelSort(words, func(a, b string) bool { return a < b }, workers)
 
 	// Create word-frequency array
 	wfreqs := make([]*word, len(words))
@@ -249,7 +393,7 @@ func (b *Builder) Build() *Index {
 	}
 
 	// Create frequency-word map
-	sort.Sort(byFrequency(wfreqs))
+	parallelSort(wfreqs, byFrequency, workers)
 	freqs := make([]int, len(words))
 	freqword := make([]uint32, len(words))
 	for i, wf := range wfreqs {
@@ -259,65 +403,82 @@ func (b *Builder) Build() *Index {
 		wf.freq = i
 	}
 
+	// Create word popularity array
+	var wpop []uint32
+	if wordpop != nil {
+		wpop = make([]uint32, len(words))
+		for i, w := range words {
+			wpop[i] = uint32(min(wordpop[w], math.MaxUint32))
+		}
+	}
+
 	// Create character frequency array
 	cavg := ((nchars / len(words)) / 2) + 1
-	charfreq := getCharFreq(words, freqs, cavg)
+	charfreq := getCharFreq(words, freqs, cavg, workers)
 
 	// Create blocks
-	blockSize := (wordcount / numBlocks) + 1
-	blocks, wordBlock := createBlocks(
-		numBlocks,
-		blockSize,
-		words,
-		freqs,
-		cavg,
-	)
-
-	// Put postings to blocks
-	for i, p := range posts {
-		widx := wordmap[*p.word].id
-		bidx := wordBlock(widx)
-		blocks[bidx].posts = append(blocks[bidx].posts, &posts[i])
+	layout := b.opts.layout(wordcount)
+	var blocks []block
+	var wordBlock func(int) int
+	switch layout.Strategy {
+	case EvenBlocks:
+		blocks = createEvenBlocks(layout.BlockSize, freqs)
+		wordBlock = blockMap(blocks)
+	case CustomBlocks:
+		blocks = createCustomBlocks(layout.Boundaries, words, freqs)
+		wordBlock = blockMap(blocks)
+	default:
+		blocks, wordBlock = createBlocks(
+			layout.NumBlocks,
+			layout.BlockSize,
+			words,
+			freqs,
+			cavg,
+			layout.PrefixLen,
+		)
 	}
 
-	// Compress blocks
-	pblocks := make([]*pblock, len(blocks))
-	for i, blk := range blocks {
+	// Put postings to blocks and compress
+	// them as soon as a chunk is filled
+	packer := newPacker(workers, len(blocks), layout.ChunkSize)
+	chunkers := make([]*chunker, len(blocks))
+	for i := range chunkers {
+		chunkers[i] = newChunker(packer)
+	}
 
-		// Create arrays for packing
-		pids := []uint32{}
-		pwords := []uint32{}
-		pranks := []uint32{}
-		bp128.MakeAlignedSlice(blk.length, &pids)
-		bp128.MakeAlignedSlice(blk.length, &pwords)
-		bp128.MakeAlignedSlice(blk.length, &pranks)
-
-		for j, p := range blk.posts {
-			pids[j] = uint32(p.id)
-			pranks[j] = uint32(p.rank)
-			pwords[j] = uint32(wordmap[*p.word].freq)
-		}
+	phrases.build()
+	if err := stream.rewind(); err != nil {
+		b.err = err
+		return nil
+	}
 
-		nchunks := blk.length / postingsChunkSize
-		if blk.length%postingsChunkSize > 0 {
-			nchunks++
+	i := 0
+	for d, ok := stream.next(); ok; d, ok = stream.next() {
+		for _, w := range d.words {
+			wf := wordmap[w]
+			bidx := wordBlock(wf.id)
+			chunkers[bidx].add(uint32(d.id), uint32(wf.freq), uint32(ranks[i]))
 		}
 
-		posts := make([]cposting, nchunks)
-		for k := range posts {
-			start := k * postingsChunkSize
-			end := min(start+postingsChunkSize, blk.length)
+		phrases.fill(d)
+		i++
+	}
 
-			posts[k].ids = bp128.DeltaPack(pids[start:end])
-			posts[k].words = bp128.Pack(pwords[start:end])
-			posts[k].ranks = bp128.Pack(pranks[start:end])
+	for _, c := range chunkers {
+		c.flush()
+	}
+	packer.wait()
 
-			posts[k].iboundary = pids[end-1]
-		}
+	if err := stream.err(); err != nil {
+		b.err = err
+		return nil
+	}
 
-		// Create packed block
+	// Create packed blocks
+	pblocks := make([]*pblock, len(blocks))
+	for i, blk := range blocks {
 		pb := &pblock{}
-		pb.posts = posts
+		pb.posts = chunkers[i].chunks()
 		pb.length = blk.length
 		pb.boundary = blk.boundary
 		pb.wboundary = [2]string{
@@ -330,6 +491,8 @@ func (b *Builder) Build() *Index {
 
 	// Caculate index size
 	size := nchars
+	size += phrases.table.size()
+	size += 4 * len(wpop)
 	size += 4 * len(freqword)
 	size += 4 * (256 * len(charfreq[0]))
 	for _, b := range pblocks {
@@ -340,28 +503,135 @@ func (b *Builder) Build() *Index {
 		}
 	}
 
-	return &Index{
+	idx := &Index{
 		blocks:   pblocks,
 		words:    words,
 		freqword: freqword,
 		charfreq: charfreq,
+		phrases:  phrases.table,
+		wordpop:  wpop,
+		ndocs:    len(ranks),
+		maxRank:  maxRank,
+		layout:   layout,
 		size:     size,
 	}
+
+	if b.topLen > 0 && b.topK > 0 {
+		idx.prefixes = buildPrefixTable(idx, b.topLen, b.topK, workers)
+		idx.size += idx.prefixes.size()
+	}
+
+	if b.cache != nil {
+		idx.SetCache(b.cache)
+	}
+
+	if b.metrics != nil {
+		idx.metrics = b.metrics
+		b.metrics.ObserveBuild(BuildMetrics{
+			Documents: idx.ndocs,
+			Words:     len(idx.words),
+			Postings:  wordcount,
+			Size:      idx.size,
+			Duration:  time.Since(s.start),
+		})
+	}
+
+	return idx
+}
+
+// normalizeRanks replaces the ranks with their
+// position when sorted in increasing order. Ties
+// are broken by the given policy.
+func normalizeRanks(ranks, counts []int, ties TieBreak, workers int) {
+	order := make([]int, len(ranks))
+	for i := range order {
+		order[i] = i
+	}
+
+	before := ties.before(counts)
+	parallelSort(order, func(a, b int) bool {
+		if ranks[a] != ranks[b] {
+			return ranks[a] < ranks[b]
+		}
+
+		return before(a, b)
+	}, workers)
+
+	ties.setPositions(ranks, order, func(a, b int) bool {
+		return ranks[a] == ranks[b]
+	})
+}
+
+// chunker packs the postings of a block into chunks.
+type chunker struct {
+	buf *chunkBuf
+	n   int
+
+	packer *packer
+	posts  []*cposting
+}
+
+func newChunker(p *packer) *chunker {
+	return &chunker{buf: newChunkBuf(p.chunkSize), packer: p}
+}
+
+// add appends a posting and packs
+// the chunk if it becomes full.
+func (c *chunker) add(id, word, rank uint32) {
+	c.buf.ids[c.n] = id
+	c.buf.words[c.n] = word
+	c.buf.ranks[c.n] = rank
+	c.n++
+
+	if c.n == len(c.buf.ids) {
+		c.pack()
+	}
+}
+
+func (c *chunker) pack() {
+	if c.n == 0 {
+		return
+	}
+
+	p := &cposting{}
+	c.posts = append(c.posts, p)
+	c.buf = c.packer.pack(c.buf, c.n, p)
+	c.n = 0
+}
+
+// flush packs the remaining postings. It must be
+// called before waiting for the packer to finish.
+func (c *chunker) flush() {
+	c.pack()
+}
+
+// chunks returns the packed chunks. It must be called
+// after the packer has finished.
+func (c *chunker) chunks() []cposting {
+	posts := make([]cposting, len(c.posts))
+	for i, p := range c.posts {
+		posts[i] = *p
+	}
+
+	return posts
 }
 
 // createBlocks creates blocks by grouping words
 // with the same prefix. This is done to minimize
-// merging when searching.
+// merging when searching. If prefixLen is zero,
+// the prefix length is chosen from nblocks.
 func createBlocks(
 	nblocks int,
 	blockSize int,
 	words []string,
 	freqs []int,
-	cavg int) ([]block, func(int) int) {
+	cavg int,
+	prefixLen int) ([]block, func(int) int) {
 
-	sum := make([]int, cavg)
-	start := make([]int, cavg)
-	blocks := make([][]block, cavg)
+	depth := max(cavg, prefixLen)
+	sum := make([]int, depth)
+	start := make([]int, depth)
+	blocks := make([][]block, depth)
 
 	prev := 0
 	for i, w := range words {
@@ -401,32 +671,18 @@ func createBlocks(
 	}
 
 	blk := blocks[len(blocks)-1]
-	for _, b := range blocks {
-		if len(b) >= nblocks {
-			blk = b
-			break
-		}
-	}
-
-	cblk := make([]block, len(blk))
-	copy(cblk, blk)
-	for i := range cblk {
-		cblk[i].index = i
-	}
-
-	sort.Sort(byLen(cblk))
-	mapFunc := func(w int) int {
-		for _, b := range cblk {
-			if w >= b.boundary[0] && w <= b.boundary[1] {
-				return b.index
+	if prefixLen > 0 {
+		blk = blocks[prefixLen-1]
+	} else {
+		for _, b := range blocks {
+			if len(b) >= nblocks {
+				blk = b
+				break
 			}
 		}
-
-		// Should not reach here
-		return -1
 	}
 
-	return blk, mapFunc
+	return blk, blockMap(blk)
 }
 
 // getCharFreq returns a [x][y]uint32 array (cfreq) which contains
@@ -439,7 +695,7 @@ func createBlocks(
 // ddc - 4
 // cfreq['b'][1] = max(freq(abc), freq(bbc)) = 3
 // cfreq['c'][2] = max(freq(abc) + freq(bbc), freq(ddc)) = 5
-func getCharFreq(words []string, freqs []int, cavg int) [][]uint32 {
+func getCharFreq(words []string, freqs []int, cavg, workers int) [][]uint32 {
 	cfreq := make([][]uint32, 256)
 	for i := range cfreq {
 		cfreq[i] = make([]uint32, cavg)
@@ -456,35 +712,56 @@ func getCharFreq(words []string, freqs []int, cavg int) [][]uint32 {
 	// S is the set of all characters that precedes the
 	// character at position i, and x is the character at
 	// position i. Then cfreq[x][i] is the maximum frequency
-	// among all the frequencies of characters in S.
-	for i := 1; i < cavg; i++ {
-		ctmp := [256][256]int{}
-
-		for j, w := range words {
-			if i < len(w) {
-				ctmp[w[i]][w[i-1]] += freqs[j]
-			}
+	// among all the frequencies of characters in S. Since
+	// each position is independent, they are computed by
+	// the workers concurrently.
+	if workers <= 1 {
+		for i := 1; i < cavg; i++ {
+			charFreqAt(cfreq, words, freqs, i)
 		}
 
-		for j := range ctmp {
-			max := 0
-			for _, v := range ctmp[j] {
-				if v > max {
-					max = v
-				}
-			}
+		return cfreq
+	}
 
-			cfreq[j][i] = uint32(max)
-		}
+	positions := make(chan int, cavg)
+	for i := 1; i < cavg; i++ {
+		positions <- i
 	}
+	close(positions)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < min(workers, cavg); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range positions {
+				charFreqAt(cfreq, words, freqs, i)
+			}
+		}()
+	}
+	wg.Wait()
 
 	return cfreq
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// charFreqAt computes cfreq[x][i] for all characters x.
+func charFreqAt(cfreq [][]uint32, words []string, freqs []int, i int) {
+	ctmp := [256][256]int{}
+
+	for j, w := range words {
+		if i < len(w) {
+			ctmp[w[i]][w[i-1]] += freqs[j]
+		}
 	}
 
-	return b
+	for j := range ctmp {
+		max := 0
+		for _, v := range ctmp[j] {
+			if v > max {
+				max = v
+			}
+		}
+
+		cfreq[j][i] = uint32(max)
+	}
 }
@@ -0,0 +1,266 @@
+package hyb
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// CacheOptions configures the search cache of an index.
+// Zero or negative limits remove the limit.
+type CacheOptions struct {
+	// MaxEntries is the maximum number
+	// of search results in the cache.
+	MaxEntries int
+
+	// MaxBytes is the approximate maximum
+	// memory used by the cached results.
+	MaxBytes int
+
+	// WarmPrefixLen is the length in characters of the
+	// prefixes that are searched when the cache is set, so
+	// that they are cached before the first search. Prefixes
+	// with more postings are cached last so that they are
+	// the last to be evicted. It defaults to zero which
+	// disables warming.
+	WarmPrefixLen int
+}
+
+// CacheStats contains the counters of a search cache.
+type CacheStats struct {
+	Hits    int
+	Misses  int
+	Entries int
+	Bytes   int
+}
+
+// searchCache keeps the results of searching a query word
+// given the words that the previous result matches. It is
+// safe for concurrent use.
+type searchCache struct {
+	mu sync.Mutex
+
+	// lru is ordered from the most to
+	// the least recently used entry.
+	lru     *list.List
+	entries map[string]*list.Element
+
+	maxEntries int
+	maxBytes   int
+	size       int
+
+	hits   int
+	misses int
+}
+
+type cacheEntry struct {
+	key string
+
+	results     []iposting
+	completions []completion
+	wrange      *[2]uint32
+
+	size int
+}
+
+// SetCache puts a bounded cache in front of the search of
+// each query word. The cache is keyed by the query word
+// and the words that the previous result matches, so it
+// also serves continuations. Since popular prefixes are
+// searched far more often than the rest, this skips most
+// of the work of a skewed query stream. It must be called
+// before searching the index. Passing nil removes the
+// cache.
+func (idx *Index) SetCache(opts *CacheOptions) {
+	if opts == nil {
+		idx.cache = nil
+		return
+	}
+
+	idx.cache = &searchCache{
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		maxEntries: max(opts.MaxEntries, 0),
+		maxBytes:   max(opts.MaxBytes, 0),
+	}
+
+	if opts.WarmPrefixLen > 0 {
+		idx.warmCache(opts.WarmPrefixLen)
+	}
+}
+
+// SetCache makes Build set the search cache of the
+// index, which also warms it. See Index.SetCache.
+func (b *Builder) SetCache(opts *CacheOptions) {
+	b.cache = opts
+}
+
+// CacheStats returns the counters of the search
+// cache. It is empty if the index has no cache.
+func (idx *Index) CacheStats() CacheStats {
+	c := idx.cache
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: c.lru.Len(),
+		Bytes:   c.size,
+	}
+}
+
+// warmCache searches every prefix of the words
+// of the index with at most n characters.
+func (idx *Index) warmCache(n int) {
+	freqs := idx.wordFreqs()
+
+	postings := map[string]int{}
+	for i, w := range idx.words {
+		for j, end := 0, 0; j < n && end < len(w); j++ {
+			_, size := utf8.DecodeRuneInString(w[end:])
+			end += size
+			postings[w[:end]] += freqs[i]
+		}
+	}
+
+	prefixes := make([]string, 0, len(postings))
+	for p := range postings {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, b := prefixes[i], prefixes[j]
+		if postings[a] != postings[b] {
+			return postings[a] < postings[b]
+		}
+
+		return a < b
+	})
+
+	// Search each prefix from scratch since a prefix
+	// that follows its own shorter prefix would only
+	// filter the previous result, which is not cached.
+	// Precomputed prefixes do not use the cache.
+	res := &Result{words: idx.words, wordpop: idx.wordpop}
+	for _, p := range prefixes {
+		if idx.prefixes.find(p) < 0 {
+			res.clear()
+			idx.search(context.Background(), p, res)
+		}
+	}
+
+	// Warming is not a cache miss
+	c := idx.cache
+	c.mu.Lock()
+	c.misses = 0
+	c.mu.Unlock()
+}
+
+// cacheKey returns the key of searching query given
+// the words that the previous result matches.
+func cacheKey(conds []string, query string) string {
+	return strings.Join(conds, "\x00") + "\x01" + query
+}
+
+// get copies the cached result of the key to r. It
+// returns false if the key is not in the cache.
+func (c *searchCache) get(key string, r *Result) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entries[key]
+	if e == nil {
+		c.misses++
+		return false
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+
+	// The entry is copied since the
+	// result is modified in place.
+	ce := e.Value.(*cacheEntry)
+	r.results = append(r.results[:0], ce.results...)
+	r.compbuf = append(r.compbuf[:0], ce.completions...)
+	r.completions = r.compbuf
+	r.wrange = ce.wrange
+	if r.wrange == nil {
+		r.clear()
+	}
+
+	return true
+}
+
+// put adds the result of the key to the cache
+// and evicts the least recently used entries.
+func (c *searchCache) put(key string, r *Result) {
+	ce := &cacheEntry{
+		key:         key,
+		results:     make([]iposting, len(r.results)),
+		completions: make([]completion, len(r.completions)),
+		wrange:      r.wrange,
+	}
+	copy(ce.results, r.results)
+	copy(ce.completions, r.completions)
+
+	ce.size = int(unsafe.Sizeof(*ce)) + 2*len(key)
+	ce.size += len(ce.results) * int(unsafe.Sizeof(iposting{}))
+	ce.size += len(ce.completions) * int(unsafe.Sizeof(completion{}))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxBytes > 0 && ce.size > c.maxBytes {
+		return
+	}
+
+	if e := c.entries[key]; e != nil {
+		c.remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(ce)
+	c.size += ce.size
+
+	for e := c.lru.Back(); e != nil; e = c.lru.Back() {
+		full := (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) ||
+			(c.maxBytes > 0 && c.size > c.maxBytes)
+
+		if !full {
+			break
+		}
+		c.remove(e)
+	}
+}
+
+func (c *searchCache) remove(e *list.Element) {
+	ce := c.lru.Remove(e).(*cacheEntry)
+	delete(c.entries, ce.key)
+	c.size -= ce.size
+}
+
+// addCond records that the results match query. A word
+// that is a prefix of another is left out since it is
+// implied. The words are kept sorted.
+func (r *Result) addCond(query string) {
+	conds := make([]string, 0, len(r.conds)+1)
+	for _, c := range r.conds {
+		if strings.HasPrefix(c, query) {
+			return
+		} else if !strings.HasPrefix(query, c) {
+			conds = append(conds, c)
+		}
+	}
+
+	i := sort.SearchStrings(conds, query)
+	conds = append(conds, "")
+	copy(conds[i+1:], conds[i:])
+	conds[i] = query
+
+	r.conds = conds
+}
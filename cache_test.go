@@ -0,0 +1,96 @@
+package hyb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchCache(t *testing.T) {
+	index, docs := createIndex("files/books.txt.gz")
+
+	b := NewBuilder()
+	b.SetCache(&CacheOptions{MaxEntries: 1000})
+	for i, d := range docs {
+		b.Add(i, strings.Fields(d), len(docs)-i)
+	}
+	cindex := b.Build()
+
+	// Type each document twice so that
+	// the second time hits the cache
+	for n := 0; n < 2; n++ {
+		for _, d := range docs[:20] {
+			expected := &Result{}
+			res := &Result{}
+			for _, q := range substrings(d) {
+				query := strings.Fields(q)
+				index.Search(query, expected)
+				cindex.Search(query, res)
+
+				assert.Equal(t, hitIDs(expected.Hits()), hitIDs(res.Hits()), q)
+				assert.Equal(t, expected.Completions(), res.Completions(), q)
+			}
+		}
+	}
+
+	s := cindex.CacheStats()
+	assert.True(t, s.Hits > 0)
+	assert.True(t, s.Misses > 0)
+	assert.True(t, s.Entries <= 1000)
+	assert.True(t, s.Bytes > 0)
+
+	// A search that hits the cache does not scan
+	res := &Result{}
+	cindex.Search([]string{"th"}, res)
+	cindex.Search([]string{"th"}, &Result{})
+	assert.Equal(t, s.Hits+1, cindex.CacheStats().Hits)
+
+	// Words that are prefixes of
+	// other words are left out
+	res = &Result{}
+	cindex.Search([]string{"the", "sta", "t"}, res)
+	assert.Equal(t, []string{"sta", "the"}, res.conds)
+}
+
+func TestSearchCacheLimits(t *testing.T) {
+	_, docs := createIndex("files/books.txt.gz")
+
+	b := NewBuilder()
+	for i, d := range docs {
+		b.Add(i, strings.Fields(d), len(docs)-i)
+	}
+	index := b.Build()
+
+	index.SetCache(&CacheOptions{MaxEntries: 10, WarmPrefixLen: 2})
+	s := index.CacheStats()
+	assert.Equal(t, 10, s.Entries)
+	assert.Equal(t, 0, s.Misses)
+
+	// The prefixes with the most postings are kept
+	res := &Result{}
+	index.Search([]string{"t"}, res)
+	assert.Equal(t, 0, res.scanned)
+	assert.Equal(t, 1, index.CacheStats().Hits)
+
+	// Every warmed prefix is cached
+	index.SetCache(&CacheOptions{WarmPrefixLen: 2})
+	for _, w := range index.words {
+		r := []rune(w)
+		for _, q := range []string{string(r[:1]), string(r[:min(2, len(r))])} {
+			index.Search([]string{q}, &Result{})
+		}
+	}
+	assert.Equal(t, 0, index.CacheStats().Misses)
+
+	index.SetCache(&CacheOptions{MaxBytes: 1 << 16})
+	for _, d := range docs[:20] {
+		for _, q := range substrings(d) {
+			index.Search(strings.Fields(q), &Result{})
+		}
+	}
+	assert.True(t, index.CacheStats().Bytes <= 1<<16)
+
+	index.SetCache(nil)
+	assert.Equal(t, CacheStats{}, index.CacheStats())
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robskie/hyb"
+)
+
+func runBuild(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: hyb build [flags] [input]\n\n")
+		fmt.Fprintf(fs.Output(), "Reads the documents from input, or the standard input if\n")
+		fmt.Fprintf(fs.Output(), "it is omitted, and writes the index to the output file.\n\n")
+		fs.PrintDefaults()
+	}
+
+	output := fs.String("o", "", "output index `file` (required)")
+	format := fs.String("format", "", "input format: jsonl, csv or tsv (default from the input extension, else jsonl)")
+	id := fs.String("id", "id", "`field` that contains the document ID")
+	keywords := fs.String("keywords", "", "comma-separated `fields` that contain the keywords (required)")
+	rank := fs.String("rank", "", "`field` that contains the document rank")
+	lower := fs.Bool("lower", false, "convert the keywords to lower case")
+	phrases := fs.Int("phrases", 0, "record word n-grams up to length `n` for phrase completions")
+	phraseDocs := fs.Int("phrase-docs", 2, "minimum number of `documents` of a phrase")
+	workers := fs.Int("workers", 0, "number of build goroutines (default GOMAXPROCS)")
+	memLimit := fs.Int("mem", 0, "spill documents to temporary files above this many `bytes`")
+	tmpDir := fs.String("tmp", "", "`directory` of the temporary files")
+	nblocks := fs.Int("blocks", 0, "nominal `number` of blocks (default 5)")
+	blockSize := fs.Int("block-size", 0, "target `postings` per block, instead of -blocks")
+	chunkSize := fs.Int("chunk-size", 0, "maximum `postings` per compressed chunk (default 2048)")
+	even := fs.Bool("even", false, "cut blocks at the block size instead of between prefixes")
+	prefixLen := fs.Int("precompute", 0, "precompute the results of the prefixes up to `n` characters")
+	prefixK := fs.Int("precompute-k", 10, "number of precomputed `hits` and completions per prefix")
+	ties := fs.String("ties", "id-desc", "order of documents with equal ranks: id-desc, id-asc, insertion or keep")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *output == "" || *keywords == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	input := io.Reader(os.Stdin)
+	name := ""
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	f, err := parseFormat(*format, name)
+	if err != nil {
+		return err
+	}
+
+	tieBreak, err := parseTieBreak(*ties)
+	if err != nil {
+		return err
+	}
+
+	opts := hyb.ImportOptions{
+		Format:   f,
+		ID:       *id,
+		Keywords: strings.Split(*keywords, ","),
+		Rank:     *rank,
+	}
+	if *lower {
+		opts.Tokenize = func(text string) []string {
+			return strings.Fields(strings.ToLower(text))
+		}
+	}
+
+	b := hyb.NewBuilder()
+	defer b.Discard()
+	b.SetWorkers(*workers)
+	b.SetMemoryLimit(*memLimit, *tmpDir)
+
+	layout := hyb.BuilderOptions{
+		NumBlocks: *nblocks,
+		BlockSize: *blockSize,
+		ChunkSize: *chunkSize,
+		TieBreak:  tieBreak,
+	}
+	if *even {
+		layout.Strategy = hyb.EvenBlocks
+	}
+	b.SetOptions(layout)
+
+	if *phrases > 1 {
+		b.EnablePhrases(*phrases, *phraseDocs)
+	}
+	b.PrecomputePrefixes(*prefixLen, *prefixK)
+
+	n, err := b.Import(input, opts)
+	if err != nil {
+		return err
+	}
+
+	idx := b.Build()
+	if err := b.Err(); err != nil {
+		return err
+	}
+
+	if err := writeIndex(*output, idx); err != nil {
+		return err
+	}
+
+	fmt.Printf("indexed %d documents, %d bytes\n", n, idx.Size())
+	return nil
+}
+
+// parseFormat returns the import format given its name.
+// If name is empty, it is taken from the input extension.
+func parseFormat(name, input string) (hyb.Format, error) {
+	formats := map[string]hyb.Format{
+		"jsonl":  hyb.JSONL,
+		"ndjson": hyb.JSONL,
+		"csv":    hyb.CSV,
+		"tsv":    hyb.TSV,
+	}
+
+	if name == "" {
+		ext := filepath.Ext(strings.TrimSuffix(input, ".gz"))
+		if f, ok := formats[strings.ToLower(strings.TrimPrefix(ext, "."))]; ok {
+			return f, nil
+		}
+		return hyb.JSONL, nil
+	}
+
+	if f, ok := formats[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+
+	return 0, fmt.Errorf("unknown format %q", name)
+}
+
+// parseTieBreak returns the tie-break policy given its name.
+func parseTieBreak(name string) (hyb.TieBreak, error) {
+	for _, t := range []hyb.TieBreak{
+		hyb.DescendingID,
+		hyb.AscendingID,
+		hyb.InsertionOrder,
+		hyb.KeepTies,
+	} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown tie-break policy %q", name)
+}
+
+// writeIndex writes the index to a file. The
+// file is removed if the index cannot be written.
+func writeIndex(name string, idx *hyb.Index) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = idx.Write(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return errors.Join(err, os.Remove(name))
+	}
+
+	return nil
+}
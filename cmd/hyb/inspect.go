@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func runStats(args []string) error {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: hyb stats index [word...]\n")
+		return errUsage
+	}
+
+	idx, err := readIndex(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Print the document frequency
+	// and the postings of each word
+	if len(args) > 1 {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "word\tdocuments\tpostings")
+		for _, w := range args[1:] {
+			docs, postings := idx.WordStats(w)
+			fmt.Fprintf(tw, "%s\t%d\t%d\n", w, docs, postings)
+		}
+
+		return tw.Flush()
+	}
+
+	hash, err := idx.Hash()
+	if err != nil {
+		return err
+	}
+
+	s := idx.Stats()
+	fmt.Printf("words:     %d\n", s.Words)
+	fmt.Printf("documents: %d\n", s.Documents)
+	fmt.Printf("postings:  %d\n", s.Postings)
+	fmt.Printf("blocks:    %d\n", len(s.Blocks))
+	fmt.Printf("chunks:    %d\n", s.Chunks)
+	fmt.Printf("phrases:   %d\n", s.Phrases)
+	fmt.Printf("prefixes:  %d\n", s.Prefixes)
+	fmt.Printf("charfreq:  %d positions\n", s.CharFreqDepth)
+	fmt.Printf(
+		"postings:  %d bytes (ids %d, words %d, ranks %d)\n",
+		s.Bytes.Total(),
+		s.Bytes.IDs,
+		s.Bytes.Words,
+		s.Bytes.Ranks,
+	)
+	fmt.Printf("size:      %d bytes\n", s.Size)
+	fmt.Printf("hash:      %s\n", hash)
+
+	layout := idx.Layout()
+	fmt.Printf(
+		"layout:    %v blocks of %d postings, chunks of %d postings\n\n",
+		layout.Strategy,
+		layout.BlockSize,
+		layout.ChunkSize,
+	)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "block\tfirst word\tlast word\tpostings\tchunks\tid bytes\tword bytes\trank bytes")
+	for i, b := range s.Blocks {
+		fmt.Fprintf(
+			tw,
+			"%d\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			i,
+			b.FirstWord,
+			b.LastWord,
+			b.Postings,
+			b.Chunks,
+			b.Bytes.IDs,
+			b.Bytes.Words,
+			b.Bytes.Ranks,
+		)
+	}
+
+	return tw.Flush()
+}
+
+func runDump(args []string) error {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: hyb dump index\n")
+		return errUsage
+	}
+
+	idx, err := readIndex(args[0])
+	if err != nil {
+		return err
+	}
+
+	return idx.Dump(os.Stdout)
+}
@@ -0,0 +1,100 @@
+// Command hyb builds, queries and inspects hyb index files.
+//
+// Usage:
+//
+//	hyb build [flags] [input]
+//	hyb query [flags] index query...
+//	hyb repl [flags] index
+//	hyb stats index [word...]
+//	hyb dump index
+//
+// Run "hyb <command> -h" for the flags of each command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/robskie/hyb"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// errUsage is returned by a command after printing its
+// usage because of missing or invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+var commands = []command{
+	{"build", "create an index file from a document stream", runBuild},
+	{"query", "search an index and print the top hits and completions", runQuery},
+	{"repl", "search an index on every keystroke", runREPL},
+	{"stats", "print the blocks, postings, size and hash of an index or of words", runStats},
+	{"dump", "print the words and postings of an index", runDump},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			err := c.run(os.Args[2:])
+			switch {
+			case err == nil, errors.Is(err, flag.ErrHelp):
+				return
+			case errors.Is(err, errUsage):
+				os.Exit(2)
+			}
+
+			fmt.Fprintf(os.Stderr, "hyb %s: %v\n", c.name, err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "hyb: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: hyb <command> [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-6s %s\n", c.name, c.usage)
+	}
+}
+
+// parseFlags parses the flags of a command. Since the
+// flag set prints its errors and usage, they are
+// returned as errUsage, except for the help flag.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errUsage
+	}
+
+	return err
+}
+
+// readIndex reads an index file.
+func readIndex(name string) (*hyb.Index, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	idx := hyb.NewIndex()
+	if err := idx.Read(file); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robskie/hyb"
+)
+
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: hyb query [flags] index query...\n\n")
+		fs.PrintDefaults()
+	}
+
+	nhits := fs.Int("hits", 10, "number of hits to print")
+	ncomps := fs.Int("completions", 5, "number of completions to print")
+	nphrases := fs.Int("phrases", 0, "number of phrase completions to print")
+	scorer := fs.String("scorer", "", "completion order: hits, maxrank, sumrank or popularity")
+	trace := fs.Bool("trace", false, "print the trace of the search as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	idx, err := readIndex(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	res := &hyb.Result{}
+	if *scorer != "" {
+		s, err := parseScorer(*scorer)
+		if err != nil {
+			return err
+		}
+		res.SetCompletionScorer(s)
+	}
+	res.SetTrace(*trace)
+
+	query := strings.Fields(strings.Join(fs.Args()[1:], " "))
+
+	start := time.Now()
+	idx.Search(query, res)
+	elapsed := time.Since(start)
+
+	printResult(os.Stdout, res, *nhits, *ncomps, *nphrases)
+	fmt.Printf("\n%d hits in %v\n", res.Total(), elapsed)
+
+	if *trace {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res.Trace())
+	}
+
+	return nil
+}
+
+// parseScorer returns the completion scorer given its name.
+func parseScorer(name string) (hyb.CompletionScorer, error) {
+	switch strings.ToLower(name) {
+	case "hits":
+		return hyb.ByHits, nil
+	case "maxrank":
+		return hyb.ByMaxRank, nil
+	case "sumrank":
+		return hyb.BySumRank, nil
+	case "popularity":
+		return hyb.ByPopularity, nil
+	}
+
+	return nil, fmt.Errorf("unknown scorer %q", name)
+}
+
+// printResult prints the top hits, completions
+// and phrases of a result.
+func printResult(w io.Writer, res *hyb.Result, nhits, ncomps, nphrases int) {
+	fmt.Fprintln(w, "hits:")
+	for it := res.TopHits(nhits); it.Next(); {
+		fmt.Fprintf(w, "  %d\n", it.ID())
+	}
+
+	fmt.Fprintln(w, "completions:")
+	for it := res.TopCompletions(ncomps); it.Next(); {
+		c := it.Completion()
+		fmt.Fprintf(w, "  %-20s %d\n", c.Word, c.Hits)
+	}
+
+	if nphrases > 0 {
+		fmt.Fprintln(w, "phrases:")
+		for it := res.TopPhrases(nphrases); it.Next(); {
+			c := it.Completion()
+			fmt.Fprintf(w, "  %-20s %d\n", c.Word, c.Hits)
+		}
+	}
+}
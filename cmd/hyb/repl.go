@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/robskie/hyb"
+)
+
+// Control keys read in raw mode.
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+func runREPL(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: hyb repl [flags] index\n\n")
+		fmt.Fprintf(fs.Output(), "Searches the index on every keystroke reusing the same\n")
+		fmt.Fprintf(fs.Output(), "result. Enter or Ctrl-U clears the query and Ctrl-C or\n")
+		fmt.Fprintf(fs.Output(), "Ctrl-D quits.\n\n")
+		fs.PrintDefaults()
+	}
+
+	nhits := fs.Int("hits", 10, "number of hits to show")
+	ncomps := fs.Int("completions", 5, "number of completions to show")
+	nphrases := fs.Int("phrases", 0, "number of phrase completions to show")
+	scorer := fs.String("scorer", "", "completion order: hits, maxrank, sumrank or popularity")
+	lower := fs.Bool("lower", false, "convert the query to lower case")
+	history := fs.Int("history", 64, "number of previous results restored on backspace")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	idx, err := readIndex(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	res := &hyb.Result{}
+	res.SetHistory(*history, 0)
+	if *scorer != "" {
+		s, err := parseScorer(*scorer)
+		if err != nil {
+			return err
+		}
+		res.SetCompletionScorer(s)
+	}
+
+	restore, err := rawMode()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	var (
+		input   []rune
+		elapsed time.Duration
+	)
+
+	screen := &bytes.Buffer{}
+	draw := func() {
+		screen.Reset()
+
+		// Clear the screen and print the prompt
+		fmt.Fprintf(screen, "\x1b[H\x1b[2J> %s\n\n", string(input))
+		if len(input) > 0 {
+			printResult(screen, res, *nhits, *ncomps, *nphrases)
+			fmt.Fprintf(
+				screen,
+				"\n%d hits in %v (%v)\n",
+				res.Total(),
+				elapsed,
+				res.Path(),
+			)
+		}
+
+		// Raw mode needs explicit carriage returns.
+		// Then move the cursor back to the prompt.
+		out := bytes.ReplaceAll(screen.Bytes(), []byte("\n"), []byte("\r\n"))
+		out = append(out, fmt.Sprintf("\x1b[1;%dH", len(input)+3)...)
+		os.Stdout.Write(out)
+	}
+	draw()
+
+	r := bufio.NewReader(os.Stdin)
+	for {
+		c, _, err := r.ReadRune()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch {
+		case c == keyCtrlC || c == keyCtrlD:
+			os.Stdout.WriteString("\x1b[H\x1b[2J")
+			return nil
+		case c == keyBackspace || c == keyDelete:
+			if len(input) == 0 {
+				continue
+			}
+			input = input[:len(input)-1]
+		case c == keyCtrlU || c == '\r' || c == '\n':
+			input = input[:0]
+		case c == keyEscape:
+			skipEscape(r)
+			continue
+		case c == utf8.RuneError || !unicode.IsPrint(c):
+			continue
+		default:
+			input = append(input, c)
+		}
+
+		text := string(input)
+		if *lower {
+			text = strings.ToLower(text)
+		}
+
+		if query := strings.Fields(text); len(query) > 0 {
+			start := time.Now()
+			idx.Search(query, res)
+			elapsed = time.Since(start)
+		}
+
+		draw()
+	}
+}
+
+// skipEscape discards the rest of an escape
+// sequence, for example, from the arrow keys.
+func skipEscape(r *bufio.Reader) {
+	c, err := r.ReadByte()
+	if err != nil || (c != '[' && c != 'O') {
+		return
+	}
+
+	// The final byte of a control sequence
+	// is in the range 0x40 to 0x7e.
+	for {
+		c, err := r.ReadByte()
+		if err != nil || (c >= 0x40 && c <= 0x7e) {
+			return
+		}
+	}
+}
+
+// rawMode puts the terminal in raw mode so that each
+// keystroke is read immediately without being echoed.
+// It returns a function that restores the terminal.
+func rawMode() (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the terminal state (%v)", err)
+	}
+
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("cannot enable raw mode (%v)", err)
+	}
+
+	return func() {
+		stty(strings.TrimSpace(state))
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,89 @@
+package hyb
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrInvalidCursor is returned when a cursor
+// token cannot be decoded.
+var ErrInvalidCursor = errors.New("hyb: invalid cursor")
+
+const (
+	hitCursorKind  = 'h'
+	compCursorKind = 'c'
+)
+
+// compCursor marks the position of a completion.
+// It uses the word string instead of the word id
+// so that it remains valid across indexes.
+type compCursor struct {
+	score float64
+	word  string
+}
+
+// before returns true if the cursor comes
+// before the given completion.
+func (c compCursor) before(score float64, word string) bool {
+	if c.score != score {
+		return c.score > score
+	}
+
+	return c.word < word
+}
+
+func encodeHitCursor(p iposting) string {
+	buf := make([]byte, 1, 1+2*binary.MaxVarintLen32)
+	buf[0] = hitCursorKind
+	buf = binary.AppendUvarint(buf, uint64(p.rank))
+	buf = binary.AppendUvarint(buf, uint64(p.id))
+
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeHitCursor(cursor string) (iposting, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(buf) == 0 || buf[0] != hitCursorKind {
+		return iposting{}, ErrInvalidCursor
+	}
+	buf = buf[1:]
+
+	rank, n := binary.Uvarint(buf)
+	if n <= 0 || rank > math.MaxUint32 {
+		return iposting{}, ErrInvalidCursor
+	}
+	buf = buf[n:]
+
+	id, n := binary.Uvarint(buf)
+	if n <= 0 || n != len(buf) || id > math.MaxUint32 {
+		return iposting{}, ErrInvalidCursor
+	}
+
+	return iposting{id: uint32(id), rank: uint32(rank)}, nil
+}
+
+func encodeCompCursor(score float64, word string) string {
+	buf := make([]byte, 1, 1+binary.MaxVarintLen64+len(word))
+	buf[0] = compCursorKind
+	buf = binary.AppendUvarint(buf, math.Float64bits(score))
+	buf = append(buf, word...)
+
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeCompCursor(cursor string) (compCursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(buf) == 0 || buf[0] != compCursorKind {
+		return compCursor{}, ErrInvalidCursor
+	}
+	buf = buf[1:]
+
+	score, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return compCursor{}, ErrInvalidCursor
+	}
+
+	return compCursor{math.Float64frombits(score), string(buf[n:])}, nil
+}
@@ -0,0 +1,166 @@
+package hyb
+
+import "unsafe"
+
+// resultState is a snapshot of a search result.
+type resultState struct {
+	index   *Index
+	query   []string
+	results []iposting
+
+	words  []string
+	wrange *[2]uint32
+
+	completions []completion
+
+	phrases *phraseTable
+	wordpop []uint32
+	conds   []string
+	partial bool
+	total   int
+
+	size int
+}
+
+// historyStack contains the states of the
+// previous searches of a result. The most
+// recent state is at the end.
+type historyStack struct {
+	states []resultState
+
+	depth    int
+	maxBytes int
+	size     int
+}
+
+// SetHistory makes the result keep the states of up to
+// depth previous searches using at most maxBytes bytes.
+// Searching a query that is in the history, for example,
+// after a backspace, restores its state without searching
+// the index. The oldest states are discarded first. Set
+// depth to zero to disable the history, which is the
+// default. A maxBytes of zero removes the memory limit,
+// the same as for CacheOptions and SessionCache.
+func (r *Result) SetHistory(depth, maxBytes int) {
+	h := &r.history
+	h.depth = max(depth, 0)
+	h.maxBytes = max(maxBytes, 0)
+	h.evict()
+}
+
+// save pushes a copy of the current state of the
+// result to its history unless it is empty or the
+// query is the same as the current query.
+func (r *Result) save(query []string) {
+	h := &r.history
+	if h.depth == 0 || r.index == nil || equalQuery(r.query, query) {
+		return
+	}
+
+	s := resultState{
+		index:   r.index,
+		query:   r.query,
+		words:   r.words,
+		wrange:  r.wrange,
+		phrases: r.phrases,
+		wordpop: r.wordpop,
+		conds:   r.conds,
+		partial: r.partial,
+		total:   r.total,
+	}
+
+	if len(r.results) > 0 {
+		s.results = make([]iposting, len(r.results))
+		copy(s.results, r.results)
+	}
+
+	if len(r.completions) > 0 {
+		s.completions = make([]completion, len(r.completions))
+		copy(s.completions, r.completions)
+	}
+
+	s.size = int(unsafe.Sizeof(s))
+	s.size += len(s.results) * int(unsafe.Sizeof(iposting{}))
+	s.size += len(s.completions) * int(unsafe.Sizeof(completion{}))
+	for _, q := range s.query {
+		s.size += len(q)
+	}
+
+	h.states = append(h.states, s)
+	h.size += s.size
+	h.evict()
+}
+
+// rollback restores the most recent state in the history
+// of the result that matches the query and discards the
+// states after it. It returns false if there is none.
+func (r *Result) rollback(idx *Index, query []string) bool {
+	h := &r.history
+	for i := len(h.states) - 1; i >= 0; i-- {
+		s := h.states[i]
+		if s.index != idx || !equalQuery(s.query, query) {
+			continue
+		}
+
+		r.index = s.index
+		r.query = s.query
+		r.results = s.results
+		r.words = s.words
+		r.wrange = s.wrange
+		r.completions = s.completions
+		r.phrases = s.phrases
+		r.wordpop = s.wordpop
+		r.conds = s.conds
+		r.partial = s.partial
+		r.total = s.total
+
+		// The restored state becomes the current
+		// state, so remove it from the history.
+		for _, d := range h.states[i:] {
+			h.size -= d.size
+		}
+		h.truncate(i)
+
+		return true
+	}
+
+	return false
+}
+
+// evict removes the oldest states until the
+// history is within its depth and size limits.
+func (h *historyStack) evict() {
+	n := 0
+	for len(h.states)-n > h.depth || (h.maxBytes > 0 && h.size > h.maxBytes && n < len(h.states)) {
+		h.size -= h.states[n].size
+		n++
+	}
+
+	if n > 0 {
+		copy(h.states, h.states[n:])
+		h.truncate(len(h.states) - n)
+	}
+}
+
+// truncate shortens the history to n states
+// and releases the references to the rest.
+func (h *historyStack) truncate(n int) {
+	for i := n; i < len(h.states); i++ {
+		h.states[i] = resultState{}
+	}
+	h.states = h.states[:n]
+}
+
+func equalQuery(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,101 @@
+package hyb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultHistory(t *testing.T) {
+	index, docs := createIndex("files/books.txt.gz")
+
+	collect := func(res *Result) ([]int, []Completion) {
+		ids := []int{}
+		for it := res.Hits(); it.Next(); {
+			ids = append(ids, it.ID())
+		}
+
+		comps := []Completion{}
+		for it := res.Completions(); it.Next(); {
+			comps = append(comps, it.Completion())
+		}
+
+		return ids, comps
+	}
+
+Exit:
+	for _, d := range docs[:50] {
+		res := &Result{}
+		res.SetHistory(100, 1<<30)
+
+		subs := substrings(d)
+
+		// Type the document and
+		// then delete it backwards
+		keys := append([]string{}, subs...)
+		for i := len(subs) - 2; i >= 0; i-- {
+			keys = append(keys, subs[i])
+		}
+
+		for i, k := range keys {
+			query := strings.Fields(k)
+			index.Search(query, res)
+
+			// Backspaces should not add states
+			if i >= len(subs) {
+				nstates := len(subs) - (i - len(subs) + 2)
+				if !assert.Len(t, res.history.states, min(nstates, 100)) {
+					break Exit
+				}
+			}
+
+			expected := &Result{}
+			index.Search(query, expected)
+
+			ids, comps := collect(res)
+			eids, ecomps := collect(expected)
+			if !assert.Equal(t, eids, ids) || !assert.Equal(t, ecomps, comps) {
+				break Exit
+			}
+		}
+	}
+}
+
+func TestResultHistoryLimits(t *testing.T) {
+	index, _ := createIndex("files/books.txt.gz")
+
+	res := &Result{}
+	res.SetHistory(3, 0)
+	for _, q := range []string{"t", "th", "the", "the w", "the wo"} {
+		index.Search(strings.Fields(q), res)
+	}
+
+	queries := []string{}
+	for _, s := range res.history.states {
+		queries = append(queries, strings.Join(s.query, " "))
+	}
+	assert.Equal(t, []string{"th", "the", "the w"}, queries)
+
+	size := 0
+	for _, s := range res.history.states {
+		size += s.size
+	}
+	assert.Equal(t, size, res.history.size)
+
+	// A zero memory limit keeps every state
+	assert.Len(t, res.history.states, 3)
+
+	// Shrinking the memory limit evicts the oldest states
+	res.SetHistory(3, res.history.states[2].size)
+	assert.Len(t, res.history.states, 1)
+	assert.Equal(t, []string{"the", "w"}, res.history.states[0].query)
+
+	// Disabling the history removes all states
+	res.SetHistory(0, 0)
+	assert.Len(t, res.history.states, 0)
+	assert.Equal(t, 0, res.history.size)
+
+	index.Search(strings.Fields("the"), res)
+	assert.Len(t, res.history.states, 0)
+}
@@ -10,12 +10,16 @@ package hyb
 import (
 	"bytes"
 	"container/heap"
+	"context"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
 	"sort"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/robskie/bp128"
@@ -69,7 +73,36 @@ type Index struct {
 	// given its word position (y).
 	charfreq [][]uint32
 
+	// phrases contains the popular
+	// word n-grams of the documents.
+	phrases phraseTable
+
+	// prefixes contains the precomputed
+	// results of the short prefixes.
+	prefixes prefixTable
+
+	// wordpop contains the query log
+	// popularity of each word. It is nil
+	// if the index has no query log.
+	wordpop []uint32
+
+	// ndocs is the number of documents and
+	// maxRank is their greatest rank. Unless
+	// the tie-break policy is KeepTies, the
+	// ranks are from 0 to ndocs-1.
+	ndocs   int
+	maxRank int
+
+	layout BuilderOptions
+
 	size int
+
+	// cache is the optional search
+	// cache. It is not serialized.
+	cache *searchCache
+
+	metrics Metrics
+	tracer  Tracer
 }
 
 // NewIndex returns an empty index.
@@ -88,17 +121,51 @@ func (idx *Index) Read(r io.Reader) error {
 	return gob.NewDecoder(r).Decode(idx)
 }
 
+// Hash returns the hex-encoded SHA-256 digest of the
+// serialized index. Since Build is deterministic, indexes
+// built from the same documents, queries and options have
+// the same hash, which is kept when the index is written
+// and read back. The cache, metrics and tracer of the
+// index are not part of it.
+func (idx *Index) Hash() (string, error) {
+	h := sha256.New()
+	if err := idx.Write(h); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// indexVersion is the version of the serialized index.
+// Index files written before versioning start with the
+// blocks instead and are read as version 0. New fields
+// are appended to the end of the stream.
+const indexVersion = 2
+
 // GobEncode transforms an index into gob streams.
 func (idx *Index) GobEncode() ([]byte, error) {
 	buf := &bytes.Buffer{}
 	enc := gob.NewEncoder(buf)
 
 	err := checkErr(
+		enc.Encode(indexVersion),
 		enc.Encode(idx.blocks),
 		enc.Encode(idx.words),
 		enc.Encode(idx.freqword),
 		enc.Encode(idx.charfreq),
 		enc.Encode(idx.size),
+
+		// Version 1
+		enc.Encode(idx.phrases.phrases),
+		enc.Encode(idx.phrases.offsets),
+		enc.Encode(idx.phrases.ids),
+		enc.Encode(idx.wordpop),
+		enc.Encode(idx.ndocs),
+		enc.Encode(idx.layout),
+		enc.Encode(&idx.prefixes),
+
+		// Version 2
+		enc.Encode(idx.maxRank),
 	)
 
 	if err != nil {
@@ -108,10 +175,23 @@ func (idx *Index) GobEncode() ([]byte, error) {
 	return buf.Bytes(), err
 }
 
-// GobDecode decodes an index from gob streams.
+// GobDecode decodes an index from gob streams. The
+// fields that are missing from older versions are
+// set to their defaults.
 func (idx *Index) GobDecode(data []byte) error {
-	buf := bytes.NewReader(data)
-	dec := gob.NewDecoder(buf)
+	dec := gob.NewDecoder(bytes.NewReader(data))
+
+	version := 0
+	if err := dec.Decode(&version); err != nil {
+		// Unversioned indexes start
+		// with the blocks instead
+		version = 0
+		dec = gob.NewDecoder(bytes.NewReader(data))
+	}
+
+	if version > indexVersion {
+		return fmt.Errorf("hyb: decode failed (unsupported version %d)", version)
+	}
 
 	err := checkErr(
 		dec.Decode(&idx.blocks),
@@ -121,6 +201,26 @@ func (idx *Index) GobDecode(data []byte) error {
 		dec.Decode(&idx.size),
 	)
 
+	if err == nil && version >= 1 {
+		err = checkErr(
+			dec.Decode(&idx.phrases.phrases),
+			dec.Decode(&idx.phrases.offsets),
+			dec.Decode(&idx.phrases.ids),
+			dec.Decode(&idx.wordpop),
+			dec.Decode(&idx.ndocs),
+			dec.Decode(&idx.layout),
+			dec.Decode(&idx.prefixes),
+		)
+	} else if err == nil {
+		idx.upgrade()
+	}
+
+	if err == nil && version >= 2 {
+		err = dec.Decode(&idx.maxRank)
+	} else if err == nil && version == 1 {
+		idx.maxRank = max(idx.ndocs-1, 0)
+	}
+
 	if err != nil {
 		err = fmt.Errorf("hyb: decode failed (%v)", err)
 	}
@@ -128,6 +228,37 @@ func (idx *Index) GobDecode(data []byte) error {
 	return err
 }
 
+// upgrade sets the number of documents, the greatest
+// rank and the layout of an index read from an
+// unversioned file.
+func (idx *Index) upgrade() {
+	npostings := 0
+	for _, b := range idx.blocks {
+		npostings += b.length
+	}
+	idx.layout = BuilderOptions{}.layout(npostings)
+
+	ids := map[uint32]bool{}
+	buffer := []uint32{}
+	bp128.MakeAlignedSlice(idx.chunkSize(), &buffer)
+	for _, b := range idx.blocks {
+		for _, p := range b.posts {
+			bp128.Unpack(p.ids, &buffer)
+			for _, id := range buffer {
+				ids[id] = true
+			}
+
+			// The ranks of these indexes are positions
+			// among every added and deleted document
+			bp128.Unpack(p.ranks, &buffer)
+			for _, r := range buffer {
+				idx.maxRank = max(idx.maxRank, int(r))
+			}
+		}
+	}
+	idx.ndocs = len(ids)
+}
+
 // Size returns the size of the index in bytes.
 func (idx *Index) Size() int {
 	return idx.size
@@ -137,19 +268,73 @@ func (idx *Index) Size() int {
 // If this search is a continuation of a previous search,
 // prev should point to the previous result. This speeds
 // up the search because it only needs to consider the
-// documents included in the previous result.
+// documents included in the previous result. If prev
+// keeps a history, see Result.SetHistory, searching a
+// query that is in its history restores that result.
 func (idx *Index) Search(query []string, prev *Result) {
-	// Check if the current query is a
-	// continuation of the previous query
+	idx.SearchContext(context.Background(), query, prev)
+}
+
+// SearchContext is the same as Search, but the spans
+// of the search are children of the span in ctx. See
+// SetTracer.
+func (idx *Index) SearchContext(ctx context.Context, query []string, prev *Result) {
+	ctx, span := idx.startSpan(ctx, "hyb.Search")
+	defer idx.endSearch(span, query, prev)
+
+	prev.scanned = 0
+	prev.cacheHits = 0
+	prev.cacheMisses = 0
+	if idx.metrics != nil {
+		defer idx.observe(query, prev, time.Now())
+	}
+	if prev.tracing {
+		prev.trace = &SearchTrace{Query: query}
+		defer prev.trace.finish(prev, time.Now())
+	}
+
+	// Restore the result if the query was searched
+	// before, for example, after a backspace.
+	// Otherwise, save the previous result.
+	if prev.rollback(idx, query) {
+		prev.path = Restored
+		return
+	}
+	prev.save(query)
+
+	// Answer short prefixes from the precomputed
+	// table unless the completions are scored
+	// differently from when it was built
+	if len(query) == 1 && prev.scorer == nil && idx.precomputed(query[0], prev) {
+		prev.path = Precomputed
+		prev.query = query
+		return
+	}
+
+	// Check if the current query is a continuation of
+	// the previous query. A precomputed result only has
+	// the top hits, so it cannot be continued.
 	cont, cquery := continuation(prev.query, query)
+	if cont && (prev.index != idx || prev.partial) {
+		cont, cquery = false, query
+	}
+
+	prev.path = FullSearch
+	if cont {
+		prev.path = Continuation
+	}
 
 	// If the previous query returns no
 	// results, no need to search again
 	if cont && len(prev.results) == 0 {
+		prev.query = query
 		return
 	} else if !cont {
 		prev.clear()
+		prev.index = idx
 		prev.words = idx.words
+		prev.phrases = &idx.phrases
+		prev.wordpop = idx.wordpop
 	}
 
 	pquery := ""
@@ -159,6 +344,14 @@ func (idx *Index) Search(query []string, prev *Result) {
 
 	for _, q := range cquery {
 		if len(pquery) > 0 && strings.HasPrefix(q, pquery) {
+			var wt *WordTrace
+			var tstart time.Time
+			if prev.trace != nil {
+				wt = prev.trace.word(q)
+				wt.Filtered = true
+				tstart = time.Now()
+			}
+
 			wrange := getWordRange(q, idx.words, int(prev.wrange[0]))
 
 			var start, end uint32
@@ -172,9 +365,16 @@ func (idx *Index) Search(query []string, prev *Result) {
 			// If previous query is a prefix of the current
 			// query, just filter IDs not in word range.
 			prev.results = filter(prev.results, wrange)
+
+			if wt != nil {
+				wt.WordRange = wrange
+				wt.Results = len(prev.results)
+				wt.IntersectTime = time.Since(tstart)
+			}
 		} else {
-			idx.search(q, prev)
+			idx.search(ctx, q, prev)
 		}
+		prev.addCond(q)
 
 		if len(prev.results) == 0 {
 			break
@@ -186,10 +386,72 @@ func (idx *Index) Search(query []string, prev *Result) {
 	prev.query = query
 }
 
-func (idx *Index) search(query string, prev *Result) {
+// precomputed fills prev with the precomputed result
+// of query. It returns false if there is none.
+func (idx *Index) precomputed(query string, prev *Result) bool {
+	i := idx.prefixes.find(query)
+	if i < 0 {
+		return false
+	}
+
+	prev.clear()
+	prev.index = idx
+	prev.words = idx.words
+	prev.phrases = &idx.phrases
+	prev.wordpop = idx.wordpop
+
+	idx.prefixes.fill(i, prev)
+	prev.partial = true
+
+	return true
+}
+
+func (idx *Index) search(ctx context.Context, query string, prev *Result) {
+	cached := false
+	ctx, wspan := idx.startSpan(ctx, "hyb.search")
+	defer func() {
+		if idx.tracer != nil {
+			wspan.SetAttributes(
+				Attribute{"hyb.word.length", utf8.RuneCountInString(query)},
+				Attribute{"hyb.cached", cached},
+				Attribute{"hyb.results", len(prev.results)},
+			)
+		}
+		wspan.End()
+	}()
+
+	var wt *WordTrace
+	var start time.Time
+	if prev.trace != nil {
+		wt = prev.trace.word(query)
+		if len(idx.charfreq) > 0 {
+			wt.Estimate = calcLen(query, idx.charfreq)
+		}
+		defer func() { wt.Results = len(prev.results) }()
+	}
+
+	if c := idx.cache; c != nil {
+		key := cacheKey(prev.conds, query)
+		if c.get(key, prev) {
+			cached = true
+			prev.cacheHits++
+			if wt != nil {
+				wt.Cached = true
+				wt.WordRange = prev.wrange
+			}
+			return
+		}
+		prev.cacheMisses++
+		defer c.put(key, prev)
+	}
+
 	// Get blocks that contain the query
+	if wt != nil {
+		start = time.Now()
+	}
+	_, span := idx.startSpan(ctx, "hyb.selectBlocks")
 	blocks := []*pblock{}
-	for _, b := range idx.blocks {
+	for i, b := range idx.blocks {
 		// The first condition in the parenthesis finds the first
 		// block that contains the query. The or'd condition finds
 		// the succeeding blocks.
@@ -197,16 +459,40 @@ func (idx *Index) search(query string, prev *Result) {
 			strings.HasPrefix(b.wboundary[0], query) {
 
 			blocks = append(blocks, b)
+			if wt != nil {
+				wt.Blocks = append(wt.Blocks, BlockTrace{Block: i, Chunks: len(b.posts)})
+			}
 		}
 	}
 
+	if wt != nil {
+		wt.SelectTime = time.Since(start)
+	}
+	if idx.tracer != nil {
+		span.SetAttributes(Attribute{"hyb.blocks", len(blocks)})
+	}
+	span.End()
+
 	if len(blocks) == 0 {
 		prev.clear()
 		return
 	}
 
+	if wt != nil {
+		start = time.Now()
+	}
+	_, span = idx.startSpan(ctx, "hyb.getWordRange")
 	wrange := getWordRange(query, idx.words, blocks[0].boundary[0])
 	prev.wrange = wrange
+	if wt != nil {
+		wt.WordRange = wrange
+		wt.WordRangeTime = time.Since(start)
+	}
+	if idx.tracer != nil && wrange != nil {
+		span.SetAttributes(Attribute{"hyb.words", int(wrange[1] - wrange[0] + 1)})
+	}
+	span.End()
+
 	if wrange == nil {
 		prev.clear()
 		return
@@ -223,7 +509,7 @@ func (idx *Index) search(query string, prev *Result) {
 	wid := wrange[0]
 	comps := prev.compbuf[:rlen]
 	for i := range comps {
-		comps[i] = completion{wid, 0}
+		comps[i] = completion{word: wid}
 		wid++
 	}
 
@@ -235,16 +521,45 @@ func (idx *Index) search(query string, prev *Result) {
 
 	// Intersect postings to blocks
 	var posts []iposting
+	var scanned scanStats
 	postings := make([][]iposting, 0, len(blocks))
-	for _, b := range blocks {
-		posts, comps = intersect(
+	for i, b := range blocks {
+		if wt != nil {
+			start = time.Now()
+		}
+		_, span = idx.startSpan(ctx, "hyb.intersect")
+
+		posts, comps, scanned = intersect(
 			prev.results,
 			comps,
 			cout,
 			b,
 			wrange,
 			idx.freqword,
+			idx.chunkSize(),
 		)
+		prev.scanned += scanned.postings
+
+		if idx.tracer != nil {
+			span.SetAttributes(
+				Attribute{"hyb.block.first", b.wboundary[0]},
+				Attribute{"hyb.chunks", len(b.posts)},
+				Attribute{"hyb.chunks.unpacked", scanned.chunks},
+				Attribute{"hyb.postings.scanned", scanned.postings},
+				Attribute{"hyb.postings.matched", len(posts)},
+			)
+		}
+		span.End()
+
+		if wt != nil {
+			bt := &wt.Blocks[i]
+			bt.Unpacked = scanned.chunks
+			bt.Skipped = bt.Chunks - scanned.chunks
+			bt.Scanned = scanned.postings
+			bt.Matches = len(posts)
+			bt.Duration = time.Since(start)
+			wt.IntersectTime += bt.Duration
+		}
 
 		if len(posts) > 0 {
 			postings = append(postings, posts)
@@ -253,7 +568,28 @@ func (idx *Index) search(query string, prev *Result) {
 	prev.completions = comps
 
 	// Merge postings
+	if wt != nil {
+		start = time.Now()
+	}
+	_, span = idx.startSpan(ctx, "hyb.merge")
 	merge(&prev.results, postings)
+	if wt != nil {
+		wt.MergeTime = time.Since(start)
+	}
+	if idx.tracer != nil {
+		span.SetAttributes(
+			Attribute{"hyb.lists", len(postings)},
+			Attribute{"hyb.results", len(prev.results)},
+		)
+	}
+	span.End()
+}
+
+// scanStats counts the chunks and the
+// postings unpacked by intersect.
+type scanStats struct {
+	chunks   int
+	postings int
 }
 
 func merge(results *[]iposting, posts [][]iposting) {
@@ -314,16 +650,18 @@ func intersect(
 	cout int,
 	block *pblock,
 	wrange *[2]uint32,
-	freqword []uint32) ([]iposting, []completion) {
+	freqword []uint32,
+	chunkSize int) ([]iposting, []completion, scanStats) {
 
 	buffer := []uint32{}
-	bp128.MakeAlignedSlice(postingsChunkSize*3, &buffer)
+	bp128.MakeAlignedSlice(chunkSize*3, &buffer)
 
 	ids := buffer[0:]
-	words := ids[postingsChunkSize:]
-	ranks := words[postingsChunkSize:]
+	words := ids[chunkSize:]
+	ranks := words[chunkSize:]
 
 	i, j := 0, 0
+	scanned := scanStats{}
 	out := make([]iposting, 0, cout)
 	for _, p := range block.posts {
 		if len(results) > 0 {
@@ -337,6 +675,8 @@ func intersect(
 		bp128.Unpack(p.ids, &ids)
 		bp128.Unpack(p.words, &words)
 		bp128.Unpack(p.ranks, &ranks)
+		scanned.chunks++
+		scanned.postings += len(ids)
 
 		var pid, pwid uint32 = math.MaxUint32, math.MaxUint32
 		if len(results) > 0 {
@@ -355,7 +695,7 @@ func intersect(
 						out = append(out, ip)
 
 						if pid != rid || pwid != wid {
-							comps[wid-wrange[0]].hits++
+							comps[wid-wrange[0]].add(ranks[j])
 						}
 
 						pid = rid
@@ -374,7 +714,7 @@ func intersect(
 					out = append(out, ip)
 
 					if pid != id || pwid != wid {
-						comps[wid-wrange[0]].hits++
+						comps[wid-wrange[0]].add(ranks[j])
 					}
 
 					pid = id
@@ -384,7 +724,7 @@ func intersect(
 		}
 	}
 
-	return out, comps
+	return out, comps, scanned
 }
 
 // continuation returns true if the current
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"encoding/gob"
 	"math"
 	"math/rand"
 	"os"
@@ -152,6 +153,100 @@ func TestIndexWriteRead(t *testing.T) {
 	assert.Equal(t, parseIndex(idx), parseIndex(nidx))
 }
 
+func TestIndexReadUnversioned(t *testing.T) {
+	b := NewBuilder()
+	b.Add(0, []string{"ab", "bc", "cd"}, 2)
+	b.Add(1, []string{"ab", "cd"}, 0)
+	b.Add(2, []string{"bc"}, 1)
+	idx := b.Build()
+
+	// Index files written before versioning
+	// only contain these fields
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	assert.Nil(t, enc.Encode(idx.blocks))
+	assert.Nil(t, enc.Encode(idx.words))
+	assert.Nil(t, enc.Encode(idx.freqword))
+	assert.Nil(t, enc.Encode(idx.charfreq))
+	assert.Nil(t, enc.Encode(idx.size))
+
+	nidx := NewIndex()
+	assert.Nil(t, nidx.GobDecode(buf.Bytes()))
+	assert.Equal(t, idx.ndocs, nidx.ndocs)
+	assert.Equal(t, idx.chunkSize(), nidx.chunkSize())
+
+	expected, actual := &Result{}, &Result{}
+	idx.Search([]string{"c"}, expected)
+	nidx.Search([]string{"c"}, actual)
+	assert.Equal(t, hitIDs(expected.TopHits(10)), hitIDs(actual.TopHits(10)))
+
+	// Newer versions cannot be read
+	buf.Reset()
+	assert.Nil(t, gob.NewEncoder(buf).Encode(indexVersion+1))
+	assert.NotNil(t, NewIndex().GobDecode(buf.Bytes()))
+}
+
+func TestIndexHash(t *testing.T) {
+	_, docs := createIndex("files/books.txt.gz")
+
+	build := func(order []int, workers, memLimit int) *Index {
+		b := NewBuilder()
+		b.SetWorkers(workers)
+		if memLimit > 0 {
+			b.SetMemoryLimit(memLimit, t.TempDir())
+		}
+		b.EnablePhrases(2, 2)
+		b.PrecomputePrefixes(1, 5)
+		b.AddQuery("the", 10)
+
+		// Use duplicate ranks so
+		// that ties must be broken
+		for _, i := range order {
+			b.Add(i, strings.Fields(docs[i]), i%10)
+		}
+
+		idx := b.Build()
+		assert.Nil(t, b.Err())
+
+		return idx
+	}
+
+	order := make([]int, len(docs))
+	for i := range order {
+		order[i] = i
+	}
+
+	expected, err := build(order, 1, 0).Hash()
+	assert.Nil(t, err)
+	assert.Len(t, expected, 64)
+
+	rand.Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+	idx := build(order, 4, 16<<10)
+	actual, err := idx.Hash()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+
+	// The hash is kept after writing
+	// the index and reading it back
+	buf := &bytes.Buffer{}
+	assert.Nil(t, idx.Write(buf))
+	nidx := NewIndex()
+	assert.Nil(t, nidx.Read(buf))
+	actual, err = nidx.Hash()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+
+	// A different document gives a different hash
+	b := NewBuilder()
+	b.Add(0, []string{"ab", "bc"}, 0)
+	h1, _ := b.Build().Hash()
+	b.Add(0, []string{"ab", "bd"}, 0)
+	h2, _ := b.Build().Hash()
+	assert.NotEqual(t, h1, h2)
+}
+
 func BenchmarkIndexSearch(b *testing.B) {
 	queries := make([][]string, 0, b.N)
 	index, docs := createIndex("files/movies.txt.gz")
@@ -0,0 +1,66 @@
+package hybgrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// Client is a convenience wrapper
+// around an AutocompleteClient.
+type Client struct {
+	AutocompleteClient
+}
+
+// NewClient creates a client given a connection.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{NewAutocompleteClient(cc)}
+}
+
+// Complete searches a query and returns the given
+// number of hits and completions. Use a Session
+// instead to search the query as it is typed.
+func (c *Client) Complete(
+	ctx context.Context,
+	query string,
+	hits int,
+	completions int) (*SearchResponse, error) {
+
+	return c.Search(ctx, &SearchRequest{
+		Query:       query,
+		Hits:        int32(hits),
+		Completions: int32(completions),
+	})
+}
+
+// Session searches the successive queries of a user
+// on the same Keystrokes stream. It must not be used
+// concurrently.
+type Session struct {
+	stream Autocomplete_KeystrokesClient
+}
+
+// NewSession opens a Keystrokes stream. The
+// stream is closed when ctx is canceled.
+func (c *Client) NewSession(ctx context.Context) (*Session, error) {
+	stream, err := c.Keystrokes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{stream}, nil
+}
+
+// Search sends a request and waits for its response.
+func (s *Session) Search(req *SearchRequest) (*SearchResponse, error) {
+	if err := s.stream.Send(req); err != nil {
+		return nil, err
+	}
+
+	return s.stream.Recv()
+}
+
+// Close closes the stream.
+func (s *Session) Close() error {
+	return s.stream.CloseSend()
+}
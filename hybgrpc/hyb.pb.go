@@ -0,0 +1,567 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: hyb.proto
+
+package hybgrpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SearchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Query is split into words by the server.
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Hits, completions and phrases are the number of
+	// results to return. Zero uses the server defaults
+	// for hits and completions and returns no phrases.
+	Hits          int32 `protobuf:"varint,2,opt,name=hits,proto3" json:"hits,omitempty"`
+	Completions   int32 `protobuf:"varint,3,opt,name=completions,proto3" json:"completions,omitempty"`
+	Phrases       int32 `protobuf:"varint,4,opt,name=phrases,proto3" json:"phrases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_hyb_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hyb_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_hyb_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetHits() int32 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetCompletions() int32 {
+	if x != nil {
+		return x.Completions
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetPhrases() int32 {
+	if x != nil {
+		return x.Phrases
+	}
+	return 0
+}
+
+type Completion struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Word          string                 `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
+	Hits          int32                  `protobuf:"varint,2,opt,name=hits,proto3" json:"hits,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Completion) Reset() {
+	*x = Completion{}
+	mi := &file_hyb_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Completion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Completion) ProtoMessage() {}
+
+func (x *Completion) ProtoReflect() protoreflect.Message {
+	mi := &file_hyb_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Completion.ProtoReflect.Descriptor instead.
+func (*Completion) Descriptor() ([]byte, []int) {
+	return file_hyb_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Completion) GetWord() string {
+	if x != nil {
+		return x.Word
+	}
+	return ""
+}
+
+func (x *Completion) GetHits() int32 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+type SearchResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Query       []string               `protobuf:"bytes,1,rep,name=query,proto3" json:"query,omitempty"`
+	Total       int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Hits        []int64                `protobuf:"varint,3,rep,packed,name=hits,proto3" json:"hits,omitempty"`
+	Completions []*Completion          `protobuf:"bytes,4,rep,name=completions,proto3" json:"completions,omitempty"`
+	Phrases     []*Completion          `protobuf:"bytes,5,rep,name=phrases,proto3" json:"phrases,omitempty"`
+	// Path is how the search was performed: full,
+	// continuation, restored or precomputed.
+	Path          string `protobuf:"bytes,6,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_hyb_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hyb_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_hyb_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SearchResponse) GetQuery() []string {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetHits() []int64 {
+	if x != nil {
+		return x.Hits
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetCompletions() []*Completion {
+	if x != nil {
+		return x.Completions
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetPhrases() []*Completion {
+	if x != nil {
+		return x.Phrases
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type CompletionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Completions   []*Completion          `protobuf:"bytes,1,rep,name=completions,proto3" json:"completions,omitempty"`
+	Phrases       []*Completion          `protobuf:"bytes,2,rep,name=phrases,proto3" json:"phrases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompletionsResponse) Reset() {
+	*x = CompletionsResponse{}
+	mi := &file_hyb_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompletionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompletionsResponse) ProtoMessage() {}
+
+func (x *CompletionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hyb_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompletionsResponse.ProtoReflect.Descriptor instead.
+func (*CompletionsResponse) Descriptor() ([]byte, []int) {
+	return file_hyb_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CompletionsResponse) GetCompletions() []*Completion {
+	if x != nil {
+		return x.Completions
+	}
+	return nil
+}
+
+func (x *CompletionsResponse) GetPhrases() []*Completion {
+	if x != nil {
+		return x.Phrases
+	}
+	return nil
+}
+
+type StatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_hyb_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hyb_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_hyb_proto_rawDescGZIP(), []int{4}
+}
+
+type BlockStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FirstWord     string                 `protobuf:"bytes,1,opt,name=first_word,json=firstWord,proto3" json:"first_word,omitempty"`
+	LastWord      string                 `protobuf:"bytes,2,opt,name=last_word,json=lastWord,proto3" json:"last_word,omitempty"`
+	Postings      int64                  `protobuf:"varint,3,opt,name=postings,proto3" json:"postings,omitempty"`
+	Chunks        int64                  `protobuf:"varint,4,opt,name=chunks,proto3" json:"chunks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockStats) Reset() {
+	*x = BlockStats{}
+	mi := &file_hyb_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockStats) ProtoMessage() {}
+
+func (x *BlockStats) ProtoReflect() protoreflect.Message {
+	mi := &file_hyb_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockStats.ProtoReflect.Descriptor instead.
+func (*BlockStats) Descriptor() ([]byte, []int) {
+	return file_hyb_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BlockStats) GetFirstWord() string {
+	if x != nil {
+		return x.FirstWord
+	}
+	return ""
+}
+
+func (x *BlockStats) GetLastWord() string {
+	if x != nil {
+		return x.LastWord
+	}
+	return ""
+}
+
+func (x *BlockStats) GetPostings() int64 {
+	if x != nil {
+		return x.Postings
+	}
+	return 0
+}
+
+func (x *BlockStats) GetChunks() int64 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
+type StatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Words         int64                  `protobuf:"varint,1,opt,name=words,proto3" json:"words,omitempty"`
+	Postings      int64                  `protobuf:"varint,2,opt,name=postings,proto3" json:"postings,omitempty"`
+	Blocks        []*BlockStats          `protobuf:"bytes,3,rep,name=blocks,proto3" json:"blocks,omitempty"`
+	Size          int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsResponse) Reset() {
+	*x = StatsResponse{}
+	mi := &file_hyb_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsResponse) ProtoMessage() {}
+
+func (x *StatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hyb_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsResponse.ProtoReflect.Descriptor instead.
+func (*StatsResponse) Descriptor() ([]byte, []int) {
+	return file_hyb_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StatsResponse) GetWords() int64 {
+	if x != nil {
+		return x.Words
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetPostings() int64 {
+	if x != nil {
+		return x.Postings
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetBlocks() []*BlockStats {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+var File_hyb_proto protoreflect.FileDescriptor
+
+const file_hyb_proto_rawDesc = "" +
+	"\n" +
+	"\thyb.proto\x12\x06hyb.v1\"u\n" +
+	"\rSearchRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x12\n" +
+	"\x04hits\x18\x02 \x01(\x05R\x04hits\x12 \n" +
+	"\vcompletions\x18\x03 \x01(\x05R\vcompletions\x12\x18\n" +
+	"\aphrases\x18\x04 \x01(\x05R\aphrases\"4\n" +
+	"\n" +
+	"Completion\x12\x12\n" +
+	"\x04word\x18\x01 \x01(\tR\x04word\x12\x12\n" +
+	"\x04hits\x18\x02 \x01(\x05R\x04hits\"\xc8\x01\n" +
+	"\x0eSearchResponse\x12\x14\n" +
+	"\x05query\x18\x01 \x03(\tR\x05query\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x12\n" +
+	"\x04hits\x18\x03 \x03(\x03R\x04hits\x124\n" +
+	"\vcompletions\x18\x04 \x03(\v2\x12.hyb.v1.CompletionR\vcompletions\x12,\n" +
+	"\aphrases\x18\x05 \x03(\v2\x12.hyb.v1.CompletionR\aphrases\x12\x12\n" +
+	"\x04path\x18\x06 \x01(\tR\x04path\"y\n" +
+	"\x13CompletionsResponse\x124\n" +
+	"\vcompletions\x18\x01 \x03(\v2\x12.hyb.v1.CompletionR\vcompletions\x12,\n" +
+	"\aphrases\x18\x02 \x03(\v2\x12.hyb.v1.CompletionR\aphrases\"\x0e\n" +
+	"\fStatsRequest\"|\n" +
+	"\n" +
+	"BlockStats\x12\x1d\n" +
+	"\n" +
+	"first_word\x18\x01 \x01(\tR\tfirstWord\x12\x1b\n" +
+	"\tlast_word\x18\x02 \x01(\tR\blastWord\x12\x1a\n" +
+	"\bpostings\x18\x03 \x01(\x03R\bpostings\x12\x16\n" +
+	"\x06chunks\x18\x04 \x01(\x03R\x06chunks\"\x81\x01\n" +
+	"\rStatsResponse\x12\x14\n" +
+	"\x05words\x18\x01 \x01(\x03R\x05words\x12\x1a\n" +
+	"\bpostings\x18\x02 \x01(\x03R\bpostings\x12*\n" +
+	"\x06blocks\x18\x03 \x03(\v2\x12.hyb.v1.BlockStatsR\x06blocks\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size2\x81\x02\n" +
+	"\fAutocomplete\x127\n" +
+	"\x06Search\x12\x15.hyb.v1.SearchRequest\x1a\x16.hyb.v1.SearchResponse\x12A\n" +
+	"\vCompletions\x12\x15.hyb.v1.SearchRequest\x1a\x1b.hyb.v1.CompletionsResponse\x124\n" +
+	"\x05Stats\x12\x14.hyb.v1.StatsRequest\x1a\x15.hyb.v1.StatsResponse\x12?\n" +
+	"\n" +
+	"Keystrokes\x12\x15.hyb.v1.SearchRequest\x1a\x16.hyb.v1.SearchResponse(\x010\x01B Z\x1egithub.com/robskie/hyb/hybgrpcb\x06proto3"
+
+var (
+	file_hyb_proto_rawDescOnce sync.Once
+	file_hyb_proto_rawDescData []byte
+)
+
+func file_hyb_proto_rawDescGZIP() []byte {
+	file_hyb_proto_rawDescOnce.Do(func() {
+		file_hyb_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_hyb_proto_rawDesc), len(file_hyb_proto_rawDesc)))
+	})
+	return file_hyb_proto_rawDescData
+}
+
+var file_hyb_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_hyb_proto_goTypes = []any{
+	(*SearchRequest)(nil),       // 0: hyb.v1.SearchRequest
+	(*Completion)(nil),          // 1: hyb.v1.Completion
+	(*SearchResponse)(nil),      // 2: hyb.v1.SearchResponse
+	(*CompletionsResponse)(nil), // 3: hyb.v1.CompletionsResponse
+	(*StatsRequest)(nil),        // 4: hyb.v1.StatsRequest
+	(*BlockStats)(nil),          // 5: hyb.v1.BlockStats
+	(*StatsResponse)(nil),       // 6: hyb.v1.StatsResponse
+}
+var file_hyb_proto_depIdxs = []int32{
+	1, // 0: hyb.v1.SearchResponse.completions:type_name -> hyb.v1.Completion
+	1, // 1: hyb.v1.SearchResponse.phrases:type_name -> hyb.v1.Completion
+	1, // 2: hyb.v1.CompletionsResponse.completions:type_name -> hyb.v1.Completion
+	1, // 3: hyb.v1.CompletionsResponse.phrases:type_name -> hyb.v1.Completion
+	5, // 4: hyb.v1.StatsResponse.blocks:type_name -> hyb.v1.BlockStats
+	0, // 5: hyb.v1.Autocomplete.Search:input_type -> hyb.v1.SearchRequest
+	0, // 6: hyb.v1.Autocomplete.Completions:input_type -> hyb.v1.SearchRequest
+	4, // 7: hyb.v1.Autocomplete.Stats:input_type -> hyb.v1.StatsRequest
+	0, // 8: hyb.v1.Autocomplete.Keystrokes:input_type -> hyb.v1.SearchRequest
+	2, // 9: hyb.v1.Autocomplete.Search:output_type -> hyb.v1.SearchResponse
+	3, // 10: hyb.v1.Autocomplete.Completions:output_type -> hyb.v1.CompletionsResponse
+	6, // 11: hyb.v1.Autocomplete.Stats:output_type -> hyb.v1.StatsResponse
+	2, // 12: hyb.v1.Autocomplete.Keystrokes:output_type -> hyb.v1.SearchResponse
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_hyb_proto_init() }
+func file_hyb_proto_init() {
+	if File_hyb_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_hyb_proto_rawDesc), len(file_hyb_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_hyb_proto_goTypes,
+		DependencyIndexes: file_hyb_proto_depIdxs,
+		MessageInfos:      file_hyb_proto_msgTypes,
+	}.Build()
+	File_hyb_proto = out.File
+	file_hyb_proto_goTypes = nil
+	file_hyb_proto_depIdxs = nil
+}
@@ -0,0 +1,246 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: hyb.proto
+
+package hybgrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Autocomplete_Search_FullMethodName      = "/hyb.v1.Autocomplete/Search"
+	Autocomplete_Completions_FullMethodName = "/hyb.v1.Autocomplete/Completions"
+	Autocomplete_Stats_FullMethodName       = "/hyb.v1.Autocomplete/Stats"
+	Autocomplete_Keystrokes_FullMethodName  = "/hyb.v1.Autocomplete/Keystrokes"
+)
+
+// AutocompleteClient is the client API for Autocomplete service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Autocomplete searches a hyb index.
+type AutocompleteClient interface {
+	// Search returns the top hits and completions of a query.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// Completions returns only the completions of a query.
+	Completions(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*CompletionsResponse, error)
+	// Stats returns the statistics of the index.
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	// Keystrokes searches each query sent on the stream reusing
+	// the result of the previous query, so typing a query one
+	// keystroke at a time only searches the previous result.
+	Keystrokes(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SearchRequest, SearchResponse], error)
+}
+
+type autocompleteClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAutocompleteClient(cc grpc.ClientConnInterface) AutocompleteClient {
+	return &autocompleteClient{cc}
+}
+
+func (c *autocompleteClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, Autocomplete_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *autocompleteClient) Completions(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*CompletionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompletionsResponse)
+	err := c.cc.Invoke(ctx, Autocomplete_Completions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *autocompleteClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, Autocomplete_Stats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *autocompleteClient) Keystrokes(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SearchRequest, SearchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Autocomplete_ServiceDesc.Streams[0], Autocomplete_Keystrokes_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SearchRequest, SearchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Autocomplete_KeystrokesClient = grpc.BidiStreamingClient[SearchRequest, SearchResponse]
+
+// AutocompleteServer is the server API for Autocomplete service.
+// All implementations must embed UnimplementedAutocompleteServer
+// for forward compatibility.
+//
+// Autocomplete searches a hyb index.
+type AutocompleteServer interface {
+	// Search returns the top hits and completions of a query.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// Completions returns only the completions of a query.
+	Completions(context.Context, *SearchRequest) (*CompletionsResponse, error)
+	// Stats returns the statistics of the index.
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	// Keystrokes searches each query sent on the stream reusing
+	// the result of the previous query, so typing a query one
+	// keystroke at a time only searches the previous result.
+	Keystrokes(grpc.BidiStreamingServer[SearchRequest, SearchResponse]) error
+	mustEmbedUnimplementedAutocompleteServer()
+}
+
+// UnimplementedAutocompleteServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAutocompleteServer struct{}
+
+func (UnimplementedAutocompleteServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedAutocompleteServer) Completions(context.Context, *SearchRequest) (*CompletionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Completions not implemented")
+}
+func (UnimplementedAutocompleteServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedAutocompleteServer) Keystrokes(grpc.BidiStreamingServer[SearchRequest, SearchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Keystrokes not implemented")
+}
+func (UnimplementedAutocompleteServer) mustEmbedUnimplementedAutocompleteServer() {}
+func (UnimplementedAutocompleteServer) testEmbeddedByValue()                      {}
+
+// UnsafeAutocompleteServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AutocompleteServer will
+// result in compilation errors.
+type UnsafeAutocompleteServer interface {
+	mustEmbedUnimplementedAutocompleteServer()
+}
+
+func RegisterAutocompleteServer(s grpc.ServiceRegistrar, srv AutocompleteServer) {
+	// If the following call pancis, it indicates UnimplementedAutocompleteServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Autocomplete_ServiceDesc, srv)
+}
+
+func _Autocomplete_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AutocompleteServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Autocomplete_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AutocompleteServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Autocomplete_Completions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AutocompleteServer).Completions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Autocomplete_Completions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AutocompleteServer).Completions(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Autocomplete_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AutocompleteServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Autocomplete_Stats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AutocompleteServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Autocomplete_Keystrokes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AutocompleteServer).Keystrokes(&grpc.GenericServerStream[SearchRequest, SearchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Autocomplete_KeystrokesServer = grpc.BidiStreamingServer[SearchRequest, SearchResponse]
+
+// Autocomplete_ServiceDesc is the grpc.ServiceDesc for Autocomplete service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Autocomplete_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hyb.v1.Autocomplete",
+	HandlerType: (*AutocompleteServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _Autocomplete_Search_Handler,
+		},
+		{
+			MethodName: "Completions",
+			Handler:    _Autocomplete_Completions_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Autocomplete_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Keystrokes",
+			Handler:       _Autocomplete_Keystrokes_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "hyb.proto",
+}
@@ -0,0 +1,219 @@
+// Package hybgrpc serves autocompletion requests for a hyb index over gRPC.
+//
+// The service is defined in hyb.proto. The Keystrokes RPC keeps a hyb.Result
+// for each stream so that typing a query keystroke by keystroke only searches
+// the previous result, the same as calling Index.Search locally.
+//
+// To regenerate the Go code after changing hyb.proto, run:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//		--go-grpc_out=. --go-grpc_opt=paths=source_relative hyb.proto
+package hybgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/robskie/hyb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options configures a server. Zero values
+// are replaced by their defaults.
+type Options struct {
+	// MaxHits and MaxCompletions limit the number of
+	// hits and completions (or phrases) of a response.
+	MaxHits        int
+	MaxCompletions int
+
+	// Tokenize splits the query of a
+	// request into the words of the query.
+	Tokenize func(text string) []string
+
+	// History is the number of previous results of
+	// a Keystrokes stream restored on backspace.
+	History int
+}
+
+// Default option values.
+const (
+	DefaultHits           = 10
+	DefaultCompletions    = 5
+	DefaultMaxHits        = 100
+	DefaultMaxCompletions = 100
+	DefaultHistory        = 32
+)
+
+// Server implements AutocompleteServer.
+// It is safe for concurrent use.
+type Server struct {
+	UnimplementedAutocompleteServer
+
+	index atomic.Pointer[hyb.Index]
+	opts  Options
+}
+
+// NewServer creates a server given an index. The
+// index can be nil until SetIndex is called.
+func NewServer(idx *hyb.Index, opts Options) *Server {
+	if opts.MaxHits <= 0 {
+		opts.MaxHits = DefaultMaxHits
+	}
+	if opts.MaxCompletions <= 0 {
+		opts.MaxCompletions = DefaultMaxCompletions
+	}
+	if opts.Tokenize == nil {
+		opts.Tokenize = strings.Fields
+	}
+	if opts.History == 0 {
+		opts.History = DefaultHistory
+	}
+
+	s := &Server{opts: opts}
+	s.index.Store(idx)
+
+	return s
+}
+
+// Index returns the index that is currently served.
+func (s *Server) Index() *hyb.Index {
+	return s.index.Load()
+}
+
+// SetIndex replaces the served index. Calls in flight
+// finish with the previous index and the next query of
+// a Keystrokes stream is searched in the new index.
+func (s *Server) SetIndex(idx *hyb.Index) {
+	s.index.Store(idx)
+}
+
+// Search returns the top hits and completions of a query.
+func (s *Server) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	return s.search(ctx, req, &hyb.Result{})
+}
+
+// Completions returns the completions of a query.
+func (s *Server) Completions(ctx context.Context, req *SearchRequest) (*CompletionsResponse, error) {
+	resp, err := s.search(ctx, req, &hyb.Result{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompletionsResponse{
+		Completions: resp.Completions,
+		Phrases:     resp.Phrases,
+	}, nil
+}
+
+// Stats returns the statistics of the index.
+func (s *Server) Stats(ctx context.Context, req *StatsRequest) (*StatsResponse, error) {
+	idx := s.index.Load()
+	if idx == nil {
+		return nil, status.Error(codes.Unavailable, "no index")
+	}
+
+	st := idx.Stats()
+	resp := &StatsResponse{
+		Words:    int64(st.Words),
+		Postings: int64(st.Postings),
+		Size:     int64(st.Size),
+	}
+	for _, b := range st.Blocks {
+		resp.Blocks = append(resp.Blocks, &BlockStats{
+			FirstWord: b.FirstWord,
+			LastWord:  b.LastWord,
+			Postings:  int64(b.Postings),
+			Chunks:    int64(b.Chunks),
+		})
+	}
+
+	return resp, nil
+}
+
+// Keystrokes searches the queries of a stream
+// reusing the same result for all of them.
+func (s *Server) Keystrokes(stream Autocomplete_KeystrokesServer) error {
+	res := &hyb.Result{}
+	res.SetHistory(max(s.opts.History, 0), 0)
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		resp, err := s.search(stream.Context(), req, res)
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) search(ctx context.Context, req *SearchRequest, res *hyb.Result) (*SearchResponse, error) {
+	idx := s.index.Load()
+	if idx == nil {
+		return nil, status.Error(codes.Unavailable, "no index")
+	}
+
+	if req.Hits < 0 || req.Completions < 0 || req.Phrases < 0 {
+		return nil, status.Error(codes.InvalidArgument, "negative number of results")
+	}
+
+	nhits := count(req.Hits, DefaultHits, s.opts.MaxHits)
+	ncomps := count(req.Completions, DefaultCompletions, s.opts.MaxCompletions)
+	nphrases := count(req.Phrases, 0, s.opts.MaxCompletions)
+
+	query := s.opts.Tokenize(req.Query)
+	resp := &SearchResponse{Query: query}
+	if len(query) == 0 {
+		return resp, nil
+	}
+
+	idx.SearchContext(ctx, query, res)
+	resp.Total = int32(res.Total())
+	resp.Path = res.Path().String()
+
+	for it := res.TopHits(nhits); it.Next(); {
+		resp.Hits = append(resp.Hits, int64(it.ID()))
+	}
+
+	for it := res.TopCompletions(ncomps); it.Next(); {
+		c := it.Completion()
+		resp.Completions = append(resp.Completions, &Completion{
+			Word: c.Word,
+			Hits: int32(c.Hits),
+		})
+	}
+
+	if nphrases > 0 {
+		for it := res.TopPhrases(nphrases); it.Next(); {
+			c := it.Completion()
+			resp.Phrases = append(resp.Phrases, &Completion{
+				Word: c.Word,
+				Hits: int32(c.Hits),
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+// count returns the number of results to return
+// given the requested number. Zero means def.
+func count(n int32, def, limit int) int {
+	if n == 0 {
+		return min(def, limit)
+	}
+
+	return min(int(n), limit)
+}
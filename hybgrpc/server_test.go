@@ -0,0 +1,122 @@
+package hybgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/robskie/hyb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func buildIndex(titles ...string) *hyb.Index {
+	b := hyb.NewBuilder()
+	for i, t := range titles {
+		b.Add(i+1, strings.Fields(t), len(titles)-i)
+	}
+
+	return b.Build()
+}
+
+// serve starts a server over an in-memory
+// connection and returns a client for it.
+func serve(t *testing.T, s *Server) *Client {
+	lis := bufconn.Listen(1 << 20)
+
+	gs := grpc.NewServer()
+	RegisterAutocompleteServer(gs, s)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	cc, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+
+	return NewClient(cc)
+}
+
+func TestSearch(t *testing.T) {
+	idx := buildIndex("star wars", "star trek", "alien", "stargate")
+	c := serve(t, NewServer(idx, Options{}))
+	ctx := context.Background()
+
+	resp, err := c.Complete(ctx, "sta", 1, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sta"}, resp.Query)
+	assert.Equal(t, int32(3), resp.Total)
+	assert.Equal(t, []int64{1}, resp.Hits)
+	assert.Len(t, resp.Completions, 2)
+	assert.Equal(t, "star", resp.Completions[0].Word)
+	assert.Equal(t, int32(2), resp.Completions[0].Hits)
+	assert.Equal(t, "full", resp.Path)
+
+	comps, err := c.Completions(ctx, &SearchRequest{Query: "star w"})
+	assert.Nil(t, err)
+	assert.Len(t, comps.Completions, 1)
+	assert.Equal(t, "wars", comps.Completions[0].Word)
+
+	_, err = c.Search(ctx, &SearchRequest{Query: "a", Hits: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stats, err := c.Stats(ctx, &StatsRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), stats.Words)
+	assert.Equal(t, int64(6), stats.Postings)
+	assert.Equal(t, int64(idx.Size()), stats.Size)
+	assert.NotEmpty(t, stats.Blocks)
+}
+
+func TestKeystrokes(t *testing.T) {
+	s := NewServer(buildIndex("star wars", "star trek", "alien", "stargate"), Options{})
+	c := serve(t, s)
+
+	sess, err := c.NewSession(context.Background())
+	assert.Nil(t, err)
+
+	paths := []string{}
+	for _, q := range []string{"s", "st", "star", "star t", "star"} {
+		resp, err := sess.Search(&SearchRequest{Query: q})
+		assert.Nil(t, err)
+		paths = append(paths, resp.Path)
+	}
+	assert.Equal(t, []string{"full", "continuation", "continuation", "continuation", "restored"}, paths)
+
+	// The next query searches the new index
+	s.SetIndex(buildIndex("stardust"))
+	resp, err := sess.Search(&SearchRequest{Query: "stard"})
+	assert.Nil(t, err)
+	assert.Equal(t, "full", resp.Path)
+	assert.Equal(t, []int64{1}, resp.Hits)
+
+	assert.Nil(t, sess.Close())
+}
+
+func TestNoIndex(t *testing.T) {
+	c := serve(t, NewServer(nil, Options{}))
+	ctx := context.Background()
+
+	_, err := c.Complete(ctx, "a", 10, 5)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	_, err = c.Stats(ctx, &StatsRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	sess, err := c.NewSession(ctx)
+	assert.Nil(t, err)
+	_, err = sess.Search(&SearchRequest{Query: "a"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
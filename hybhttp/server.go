@@ -0,0 +1,324 @@
+// Package hybhttp serves autocompletion requests for a hyb index over HTTP.
+//
+// The server handles the following requests:
+//
+//	GET /complete?q=star+wa&hits=10&completions=5&phrases=0&session=abc
+//	GET /healthz
+//	GET /metrics
+//
+// Requests with the same session ID, given by the session parameter or
+// the X-Session-ID header, reuse the same hyb.Result so that typing a
+// query keystroke by keystroke only searches the previous result.
+package hybhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/robskie/hyb"
+)
+
+// Options configures a server. Zero values
+// are replaced by their defaults.
+type Options struct {
+	// MaxSessions is the maximum number of sessions
+	// whose results are kept. The least recently
+	// used sessions are discarded first.
+	MaxSessions int
+
+	// MaxSessionBytes is the approximate number of
+	// bytes used by the results of the sessions.
+	// Zero removes the limit.
+	MaxSessionBytes int
+
+	// SessionTTL is how long an unused
+	// session is kept before it expires.
+	SessionTTL time.Duration
+
+	// MaxHits and MaxCompletions limit the number
+	// of hits and completions of a response.
+	MaxHits        int
+	MaxCompletions int
+
+	// Tokenize splits the q parameter
+	// into the words of the query.
+	Tokenize func(text string) []string
+
+	// History is the number of previous results
+	// of a session restored on backspace.
+	History int
+}
+
+// Default option values.
+const (
+	DefaultMaxSessions    = 10000
+	DefaultSessionTTL     = 10 * time.Minute
+	DefaultMaxHits        = 100
+	DefaultMaxCompletions = 100
+	DefaultHistory        = 32
+)
+
+// Completion is a word completion of a response.
+type Completion struct {
+	Word string `json:"word"`
+	Hits int    `json:"hits"`
+}
+
+// Response is the JSON response to /complete.
+type Response struct {
+	Query       []string     `json:"query"`
+	Total       int          `json:"total"`
+	Hits        []int        `json:"hits"`
+	Completions []Completion `json:"completions"`
+	Phrases     []Completion `json:"phrases,omitempty"`
+	Path        string       `json:"path"`
+}
+
+// Server is an http.Handler that answers autocompletion
+// requests. It is safe for concurrent use.
+type Server struct {
+	index atomic.Pointer[hyb.Index]
+	opts  Options
+	mux   *http.ServeMux
+
+	sessions *hyb.SessionCache
+	metrics  metrics
+}
+
+type metrics struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+	reloads  atomic.Int64
+	searches [hyb.NumSearchPaths]atomic.Int64
+	nanos    atomic.Int64
+}
+
+// New creates a server given an index. The
+// index can be nil until SetIndex is called.
+func New(idx *hyb.Index, opts Options) *Server {
+	if opts.MaxSessions <= 0 {
+		opts.MaxSessions = DefaultMaxSessions
+	}
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultSessionTTL
+	}
+	if opts.MaxHits <= 0 {
+		opts.MaxHits = DefaultMaxHits
+	}
+	if opts.MaxCompletions <= 0 {
+		opts.MaxCompletions = DefaultMaxCompletions
+	}
+	if opts.Tokenize == nil {
+		opts.Tokenize = strings.Fields
+	}
+	if opts.History == 0 {
+		opts.History = DefaultHistory
+	}
+
+	s := &Server{
+		opts: opts,
+		mux:  http.NewServeMux(),
+		sessions: hyb.NewSessionCache(
+			opts.MaxSessions,
+			opts.SessionTTL,
+			opts.MaxSessionBytes,
+		),
+	}
+	s.sessions.SetHistory(max(opts.History, 0), 0)
+	s.index.Store(idx)
+
+	s.mux.HandleFunc("/complete", s.complete)
+	s.mux.HandleFunc("/healthz", s.health)
+	s.mux.HandleFunc("/metrics", s.serveMetrics)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Index returns the index that is currently served.
+func (s *Server) Index() *hyb.Index {
+	return s.index.Load()
+}
+
+// SetIndex replaces the served index. Requests in flight
+// finish with the previous index. The sessions are
+// discarded since their results refer to the previous
+// index, so that it can be garbage collected.
+func (s *Server) SetIndex(idx *hyb.Index) {
+	s.index.Store(idx)
+	s.sessions.Clear()
+	s.metrics.reloads.Add(1)
+}
+
+// Load reads an index file and serves it. The
+// current index is kept if the file cannot be read.
+func (s *Server) Load(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("hybhttp: load failed (%v)", err)
+	}
+	defer file.Close()
+
+	idx := hyb.NewIndex()
+	if err := idx.Read(file); err != nil {
+		return fmt.Errorf("hybhttp: load failed (%v)", err)
+	}
+	s.SetIndex(idx)
+
+	return nil
+}
+
+func (s *Server) complete(w http.ResponseWriter, r *http.Request) {
+	s.metrics.requests.Add(1)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		s.error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	idx := s.index.Load()
+	if idx == nil {
+		s.error(w, http.StatusServiceUnavailable, "no index")
+		return
+	}
+
+	params := r.URL.Query()
+	nhits, err1 := intParam(params, "hits", 10, s.opts.MaxHits)
+	ncomps, err2 := intParam(params, "completions", 5, s.opts.MaxCompletions)
+	nphrases, err3 := intParam(params, "phrases", 0, s.opts.MaxCompletions)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		s.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := s.opts.Tokenize(params.Get("q"))
+	resp := Response{
+		Query:       query,
+		Hits:        []int{},
+		Completions: []Completion{},
+	}
+
+	if len(query) > 0 {
+		// Requests without a session
+		// do not keep their results
+		id := sessionID(r)
+		res := &hyb.Result{}
+		if id != "" {
+			res = s.sessions.Acquire(id)
+		}
+
+		start := time.Now()
+		idx.SearchContext(r.Context(), query, res)
+		s.metrics.nanos.Add(int64(time.Since(start)))
+		s.metrics.searches[res.Path()].Add(1)
+
+		// Results of a replaced index are not kept
+		fill(&resp, res, nhits, ncomps, nphrases)
+		if id != "" && s.index.Load() == idx {
+			s.sessions.Release(id, res)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fill copies the top hits, completions
+// and phrases of a result to a response.
+func fill(resp *Response, res *hyb.Result, nhits, ncomps, nphrases int) {
+	resp.Total = res.Total()
+	resp.Path = res.Path().String()
+
+	for it := res.TopHits(nhits); it.Next(); {
+		resp.Hits = append(resp.Hits, it.ID())
+	}
+
+	for it := res.TopCompletions(ncomps); it.Next(); {
+		c := it.Completion()
+		resp.Completions = append(resp.Completions, Completion{c.Word, c.Hits})
+	}
+
+	if nphrases > 0 {
+		for it := res.TopPhrases(nphrases); it.Next(); {
+			c := it.Completion()
+			resp.Phrases = append(resp.Phrases, Completion{c.Word, c.Hits})
+		}
+	}
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	if s.index.Load() == nil {
+		http.Error(w, "no index", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// serveMetrics writes the metrics of the
+// server in the Prometheus text format.
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	size := 0
+	if idx := s.index.Load(); idx != nil {
+		size = idx.Size()
+	}
+
+	m := &s.metrics
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "hyb_requests_total %d\n", m.requests.Load())
+	fmt.Fprintf(w, "hyb_request_errors_total %d\n", m.errors.Load())
+	for p := hyb.SearchPath(0); p < hyb.NumSearchPaths; p++ {
+		fmt.Fprintf(w, "hyb_searches_total{path=%q} %d\n", p, m.searches[p].Load())
+	}
+	fmt.Fprintf(w, "hyb_search_seconds_total %g\n", time.Duration(m.nanos.Load()).Seconds())
+	fmt.Fprintf(w, "hyb_sessions %d\n", s.sessions.Len())
+	fmt.Fprintf(w, "hyb_session_bytes %d\n", s.sessions.Size())
+	fmt.Fprintf(w, "hyb_index_reloads_total %d\n", m.reloads.Load())
+	fmt.Fprintf(w, "hyb_index_bytes %d\n", size)
+}
+
+func (s *Server) error(w http.ResponseWriter, code int, msg string) {
+	s.metrics.errors.Add(1)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// sessionID returns the session ID of a request.
+func sessionID(r *http.Request) string {
+	if id := r.URL.Query().Get("session"); id != "" {
+		return id
+	}
+
+	return r.Header.Get("X-Session-ID")
+}
+
+// intParam parses a non-negative integer parameter.
+// It returns def if the parameter is missing and
+// limits the value to limit.
+func intParam(params url.Values, name string, def, limit int) (int, error) {
+	value := params.Get(name)
+	if value == "" {
+		return min(def, limit), nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s parameter %q", name, value)
+	}
+
+	return min(n, limit), nil
+}
@@ -0,0 +1,227 @@
+package hybhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robskie/hyb"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildIndex(titles ...string) *hyb.Index {
+	b := hyb.NewBuilder()
+	for i, t := range titles {
+		b.Add(i+1, strings.Fields(t), len(titles)-i)
+	}
+
+	return b.Build()
+}
+
+func get(t *testing.T, h http.Handler, query string, header http.Header) (int, Response) {
+	req := httptest.NewRequest(http.MethodGet, "/complete?"+query, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	resp := Response{}
+	if rec.Code == http.StatusOK {
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	}
+
+	return rec.Code, resp
+}
+
+func TestComplete(t *testing.T) {
+	s := New(buildIndex("star wars", "star trek", "alien", "stargate"), Options{})
+
+	code, resp := get(t, s, "q=sta&hits=1&completions=5", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"sta"}, resp.Query)
+	assert.Equal(t, 3, resp.Total)
+	assert.Equal(t, []int{1}, resp.Hits)
+	assert.Equal(t, []Completion{{"star", 2}, {"stargate", 1}}, resp.Completions)
+	assert.Equal(t, "full", resp.Path)
+
+	// Keystrokes of the same session
+	// only search the previous result
+	paths := []string{}
+	for _, q := range []string{"s", "st", "star", "star+t", "star"} {
+		code, resp = get(t, s, "session=a&q="+q, nil)
+		assert.Equal(t, http.StatusOK, code)
+		paths = append(paths, resp.Path)
+	}
+	assert.Equal(t, []string{"full", "continuation", "continuation", "continuation", "restored"}, paths)
+	assert.Equal(t, []int{1, 2, 4}, resp.Hits)
+
+	header := http.Header{"X-Session-Id": {"b"}}
+	_, resp = get(t, s, "q=a", header)
+	assert.Equal(t, "full", resp.Path)
+	_, resp = get(t, s, "q=al", header)
+	assert.Equal(t, "continuation", resp.Path)
+	assert.Equal(t, []int{3}, resp.Hits)
+
+	// Empty query
+	code, resp = get(t, s, "q=++", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, resp.Hits)
+	assert.Empty(t, resp.Completions)
+
+	// Invalid requests
+	for _, q := range []string{"q=a&hits=x", "q=a&completions=-1", "q=a&phrases=1.5"} {
+		code, _ = get(t, s, q, nil)
+		assert.Equal(t, http.StatusBadRequest, code, q)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/complete?q=a", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHealthAndMetrics(t *testing.T) {
+	s := New(nil, Options{})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	code, _ := get(t, s, "q=a", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	s.SetIndex(buildIndex("alien"))
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	get(t, s, "session=x&q=a", nil)
+	get(t, s, "session=x&q=al", nil)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, "hyb_requests_total 3\n")
+	assert.Contains(t, body, "hyb_request_errors_total 1\n")
+	assert.Contains(t, body, "hyb_searches_total{path=\"full\"} 1\n")
+	assert.Contains(t, body, "hyb_searches_total{path=\"continuation\"} 1\n")
+	assert.Contains(t, body, "hyb_sessions 1\n")
+	assert.Contains(t, body, "hyb_index_reloads_total 1\n")
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s := New(buildIndex("alien"), Options{MaxSessions: 2, SessionTTL: 50 * time.Millisecond})
+
+	get(t, s, "session=a&q=a", nil)
+	get(t, s, "session=b&q=a", nil)
+	get(t, s, "session=a&q=a", nil)
+	get(t, s, "session=c&q=a", nil)
+
+	// The least recently used session is discarded
+	assert.Equal(t, 2, s.sessions.Len())
+	_, resp := get(t, s, "session=a&q=al", nil)
+	assert.Equal(t, "continuation", resp.Path)
+	_, resp = get(t, s, "session=b&q=al", nil)
+	assert.Equal(t, "full", resp.Path)
+
+	// Expired sessions start over
+	time.Sleep(100 * time.Millisecond)
+	_, resp = get(t, s, "session=a&q=ali", nil)
+	assert.Equal(t, "full", resp.Path)
+	assert.Equal(t, 1, s.sessions.Len())
+}
+
+func TestHotSwap(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "index.hyb")
+
+	write := func(idx *hyb.Index) {
+		file, err := os.Create(name)
+		assert.Nil(t, err)
+		assert.Nil(t, idx.Write(file))
+		assert.Nil(t, file.Close())
+	}
+
+	s := New(buildIndex("star wars"), Options{})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	fetch := func(session, q string) (int, Response) {
+		params := url.Values{"q": {q}, "session": {session}}
+		r, err := http.Get(ts.URL + "/complete?" + params.Encode())
+		if err != nil {
+			return 0, Response{}
+		}
+		defer r.Body.Close()
+
+		resp := Response{}
+		json.NewDecoder(r.Body).Decode(&resp)
+		return r.StatusCode, resp
+	}
+
+	// Swap the index while serving requests
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(session string) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				code, resp := fetch(session, "sta")
+				assert.Equal(t, http.StatusOK, code)
+				assert.Len(t, resp.Hits, 1)
+			}
+		}(string(rune('a' + i)))
+	}
+
+	write(buildIndex("stargate"))
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, s.Load(name))
+	}
+	wg.Wait()
+
+	// Sessions search the new index
+	_, resp := fetch("a", "star")
+	assert.Equal(t, []Completion{{"stargate", 1}}, resp.Completions)
+
+	// A bad file keeps the current index
+	assert.Nil(t, os.WriteFile(name, []byte("garbage"), 0644))
+	assert.NotNil(t, s.Load(name))
+	assert.NotNil(t, s.Index())
+	_, resp = fetch("a", "stargate")
+	assert.Equal(t, []int{1}, resp.Hits)
+}
+
+func TestSwapReleasesIndex(t *testing.T) {
+	released := make(chan struct{})
+	idx := buildIndex("star wars")
+	runtime.SetFinalizer(idx, func(*hyb.Index) { close(released) })
+
+	s := New(idx, Options{})
+	get(t, s, "session=a&q=sta", nil)
+	get(t, s, "session=b&q=star", nil)
+	assert.Equal(t, 2, s.sessions.Len())
+
+	// The sessions of the old index are discarded
+	idx = nil
+	s.SetIndex(buildIndex("stargate"))
+	assert.Equal(t, 0, s.sessions.Len())
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case <-released:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("old index was not released")
+}
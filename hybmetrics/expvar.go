@@ -0,0 +1,75 @@
+package hybmetrics
+
+import (
+	"expvar"
+	"strconv"
+)
+
+// Expvar publishes the measurements as an expvar
+// variable, which is served by expvar at /debug/vars.
+type Expvar struct {
+	*collector
+}
+
+// NewExpvar creates a hyb.Metrics that publishes
+// its measurements under the given name. Like
+// expvar.Publish, it panics if the name is
+// already used.
+func NewExpvar(name string) *Expvar {
+	m := &Expvar{newCollector()}
+	expvar.Publish(name, expvar.Func(m.snapshot))
+
+	return m
+}
+
+// snapshot returns the measurements as a
+// value that can be encoded to JSON.
+func (m *Expvar) snapshot() any {
+	c := m.collector
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	searches := map[string]int{}
+	for _, p := range c.paths() {
+		searches[p.String()] = c.searches[p]
+	}
+
+	latency := map[string]any{}
+	for _, n := range c.queryLens() {
+		latency[strconv.Itoa(n)] = histogramValue(c.latency[n])
+	}
+
+	return map[string]any{
+		"searches":          searches,
+		"search_latency":    latency,
+		"postings_scanned":  c.scanned,
+		"postings_returned": c.results,
+		"cache_hits":        c.cacheHits,
+		"cache_misses":      c.cacheMisses,
+		"builds":            c.builds,
+		"build_latency":     histogramValue(c.buildTime),
+		"last_build": map[string]any{
+			"documents": c.lastBuild.Documents,
+			"words":     c.lastBuild.Words,
+			"postings":  c.lastBuild.Postings,
+			"size":      c.lastBuild.Size,
+			"seconds":   c.lastBuild.Duration.Seconds(),
+		},
+	}
+}
+
+// histogramValue returns the cumulative counts of the
+// buckets keyed by their upper bounds, the count and
+// the sum of a histogram.
+func histogramValue(h *histogram) map[string]any {
+	buckets := map[string]int{}
+	for i, n := range h.cumulative() {
+		buckets[strconv.FormatFloat(LatencyBuckets[i], 'g', -1, 64)] = n
+	}
+
+	return map[string]any{
+		"buckets": buckets,
+		"count":   h.count,
+		"sum":     h.sum,
+	}
+}
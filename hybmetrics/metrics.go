@@ -0,0 +1,149 @@
+// Package hybmetrics exports the measurements of hyb indexes and
+// builders, see hyb.Metrics, through expvar or in the Prometheus
+// text format. It only uses the standard library.
+//
+//	m := hybmetrics.NewPrometheus()
+//	index.SetMetrics(m)
+//	http.Handle("/metrics", m)
+package hybmetrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robskie/hyb"
+)
+
+// MaxQueryLen is the largest query length that has its own
+// latency histogram. Longer queries share the histogram
+// of this length.
+const MaxQueryLen = 10
+
+// LatencyBuckets are the upper bounds in
+// seconds of the buckets of the histograms.
+var LatencyBuckets = []float64{
+	0.00001, 0.000025, 0.00005,
+	0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005,
+	0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5,
+	1, 2.5, 5, 10,
+}
+
+// histogram counts observations in LatencyBuckets.
+// The last count is for the observations greater
+// than every bucket.
+type histogram struct {
+	counts []int
+	count  int
+	sum    float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int, len(LatencyBuckets)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	s := d.Seconds()
+	h.counts[sort.SearchFloat64s(LatencyBuckets, s)]++
+	h.count++
+	h.sum += s
+}
+
+// cumulative returns the number of observations
+// less than or equal to each bucket.
+func (h *histogram) cumulative() []int {
+	c := make([]int, len(LatencyBuckets))
+	n := 0
+	for i := range c {
+		n += h.counts[i]
+		c[i] = n
+	}
+
+	return c
+}
+
+// collector aggregates the measurements. It is
+// shared by the expvar and Prometheus versions.
+type collector struct {
+	mu sync.Mutex
+
+	searches map[hyb.SearchPath]int
+
+	// latency contains a histogram for
+	// each query length up to MaxQueryLen.
+	latency map[int]*histogram
+
+	scanned     int
+	results     int
+	cacheHits   int
+	cacheMisses int
+
+	builds    int
+	buildTime *histogram
+	lastBuild hyb.BuildMetrics
+}
+
+func newCollector() *collector {
+	return &collector{
+		searches:  map[hyb.SearchPath]int{},
+		latency:   map[int]*histogram{},
+		buildTime: newHistogram(),
+	}
+}
+
+// ObserveSearch implements hyb.Metrics.
+func (c *collector) ObserveSearch(m hyb.SearchMetrics) {
+	qlen := min(m.QueryLen, MaxQueryLen)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.searches[m.Path]++
+	c.scanned += m.Scanned
+	c.results += m.Results
+	c.cacheHits += m.CacheHits
+	c.cacheMisses += m.CacheMisses
+
+	h := c.latency[qlen]
+	if h == nil {
+		h = newHistogram()
+		c.latency[qlen] = h
+	}
+	h.observe(m.Duration)
+}
+
+// ObserveBuild implements hyb.Metrics.
+func (c *collector) ObserveBuild(m hyb.BuildMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.builds++
+	c.buildTime.observe(m.Duration)
+	c.lastBuild = m
+}
+
+// paths returns the search paths that
+// were observed sorted by their value.
+func (c *collector) paths() []hyb.SearchPath {
+	paths := make([]hyb.SearchPath, 0, len(c.searches))
+	for p := range c.searches {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+
+	return paths
+}
+
+// queryLens returns the query lengths
+// that were observed in increasing order.
+func (c *collector) queryLens() []int {
+	lens := make([]int, 0, len(c.latency))
+	for n := range c.latency {
+		lens = append(lens, n)
+	}
+	sort.Ints(lens)
+
+	return lens
+}
@@ -0,0 +1,78 @@
+package hybmetrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/robskie/hyb"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildIndex(m hyb.Metrics, titles ...string) *hyb.Index {
+	b := hyb.NewBuilder()
+	b.SetMetrics(m)
+	b.SetCache(&hyb.CacheOptions{MaxEntries: 100})
+	for i, t := range titles {
+		b.Add(i+1, strings.Fields(t), len(titles)-i)
+	}
+
+	return b.Build()
+}
+
+func search(idx *hyb.Index) {
+	res := &hyb.Result{}
+	for _, q := range []string{"s", "st", "star", "star t"} {
+		idx.Search(strings.Fields(q), res)
+	}
+	// A new session hits the cache
+	idx.Search([]string{"s"}, &hyb.Result{})
+}
+
+func TestPrometheus(t *testing.T) {
+	m := NewPrometheus()
+	search(buildIndex(m, "star wars", "star trek", "alien", "stargate"))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "hyb_searches_total{path=\"full\"} 2\n")
+	assert.Contains(t, body, "hyb_searches_total{path=\"continuation\"} 3\n")
+	assert.Contains(t, body, "hyb_search_duration_seconds_count{query_len=\"1\"} 2\n")
+	assert.Contains(t, body, "hyb_search_duration_seconds_count{query_len=\"4\"} 1\n")
+	assert.Contains(t, body, "hyb_search_duration_seconds_bucket{query_len=\"6\",le=\"+Inf\"} 1\n")
+	assert.Contains(t, body, "hyb_cache_hits_total 1\n")
+	assert.Contains(t, body, "hyb_cache_misses_total 2\n")
+	assert.Contains(t, body, "hyb_postings_scanned_total ")
+	assert.Contains(t, body, "hyb_build_duration_seconds_count 1\n")
+	assert.Contains(t, body, "hyb_build_documents 4\n")
+	assert.Contains(t, body, "hyb_build_postings 6\n")
+}
+
+func TestExpvar(t *testing.T) {
+	m := NewExpvar("hyb_test")
+	search(buildIndex(m, "star wars", "star trek", "alien", "stargate"))
+
+	v := struct {
+		Searches    map[string]int `json:"searches"`
+		CacheHits   int            `json:"cache_hits"`
+		CacheMisses int            `json:"cache_misses"`
+		Builds      int            `json:"builds"`
+		Latency     map[string]struct {
+			Count int `json:"count"`
+		} `json:"search_latency"`
+	}{}
+	assert.Nil(t, json.Unmarshal([]byte(expvar.Get("hyb_test").String()), &v))
+
+	assert.Equal(t, map[string]int{"full": 2, "continuation": 3}, v.Searches)
+	assert.Equal(t, 1, v.CacheHits)
+	assert.Equal(t, 2, v.CacheMisses)
+	assert.Equal(t, 1, v.Builds)
+	assert.Equal(t, 2, v.Latency["1"].Count)
+}
@@ -0,0 +1,95 @@
+package hybmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Prometheus serves the measurements in the
+// Prometheus text exposition format.
+type Prometheus struct {
+	*collector
+}
+
+// NewPrometheus creates a hyb.Metrics that can be
+// scraped by Prometheus through its ServeHTTP method.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{newCollector()}
+}
+
+// ServeHTTP writes the measurements.
+func (m *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.Write(w)
+}
+
+// Write writes the measurements to w
+// in the Prometheus text format.
+func (m *Prometheus) Write(w io.Writer) error {
+	c := m.collector
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# HELP hyb_searches_total Number of searches by search path.")
+	fmt.Fprintln(bw, "# TYPE hyb_searches_total counter")
+	for _, p := range c.paths() {
+		fmt.Fprintf(bw, "hyb_searches_total{path=%q} %d\n", p, c.searches[p])
+	}
+
+	fmt.Fprintln(bw, "# HELP hyb_search_duration_seconds Search latency by query length in characters.")
+	fmt.Fprintln(bw, "# TYPE hyb_search_duration_seconds histogram")
+	for _, n := range c.queryLens() {
+		labels := fmt.Sprintf("query_len=%q", strconv.Itoa(n))
+		writeHistogram(bw, "hyb_search_duration_seconds", labels, c.latency[n])
+	}
+
+	writeCounter(bw, "hyb_postings_scanned_total", "Number of postings unpacked by searches.", c.scanned)
+	writeCounter(bw, "hyb_postings_returned_total", "Number of postings of search results.", c.results)
+	writeCounter(bw, "hyb_cache_hits_total", "Number of query words found in the search cache.", c.cacheHits)
+	writeCounter(bw, "hyb_cache_misses_total", "Number of query words not found in the search cache.", c.cacheMisses)
+
+	fmt.Fprintln(bw, "# HELP hyb_build_duration_seconds Index build latency.")
+	fmt.Fprintln(bw, "# TYPE hyb_build_duration_seconds histogram")
+	writeHistogram(bw, "hyb_build_duration_seconds", "", c.buildTime)
+
+	writeGauge(bw, "hyb_build_documents", "Number of documents of the last built index.", c.lastBuild.Documents)
+	writeGauge(bw, "hyb_build_words", "Number of words of the last built index.", c.lastBuild.Words)
+	writeGauge(bw, "hyb_build_postings", "Number of postings of the last built index.", c.lastBuild.Postings)
+	writeGauge(bw, "hyb_build_bytes", "Size of the last built index in bytes.", c.lastBuild.Size)
+
+	return bw.Flush()
+}
+
+func writeCounter(w io.Writer, name, help string, v int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
+
+func writeGauge(w io.Writer, name, help string, v int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+}
+
+// writeHistogram writes the samples of a histogram
+// with the given labels, which may be empty.
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+
+	for i, n := range h.cumulative() {
+		le := strconv.FormatFloat(LatencyBuckets[i], 'g', -1, 64)
+		fmt.Fprintf(w, "%s_bucket{%s%sle=%q} %d\n", name, labels, sep, le, n)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
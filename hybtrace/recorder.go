@@ -0,0 +1,110 @@
+// Package hybtrace records the spans of hyb searches in memory, see
+// hyb.Tracer. It is meant for tests and debugging.
+//
+//	rec := hybtrace.NewRecorder()
+//	index.SetTracer(rec)
+//	index.Search(query, result)
+//	for _, s := range rec.Spans() {
+//		fmt.Println(s.Name, s.Attributes)
+//	}
+package hybtrace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robskie/hyb"
+)
+
+// SpanData is a span that has ended.
+type SpanData struct {
+	// ID identifies the span in its recorder and
+	// Parent is the ID of its parent or zero if
+	// it has none.
+	ID     int
+	Parent int
+
+	Name       string
+	Attributes map[string]any
+
+	Start time.Time
+	End   time.Time
+}
+
+// Recorder is a hyb.Tracer that keeps the spans in memory.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	spans  []SpanData
+	nextID int
+}
+
+// NewRecorder creates an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+type spanKey struct{}
+
+// Start implements hyb.Tracer.
+func (r *Recorder) Start(ctx context.Context, name string) (context.Context, hyb.Span) {
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.mu.Unlock()
+
+	s := &span{
+		recorder: r,
+		data: SpanData{
+			ID:         id,
+			Name:       name,
+			Attributes: map[string]any{},
+			Start:      time.Now(),
+		},
+	}
+	if p, ok := ctx.Value(spanKey{}).(*span); ok && p.recorder == r {
+		s.data.Parent = p.data.ID
+	}
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Spans returns the spans that have ended
+// in the order that they have ended.
+func (r *Recorder) Spans() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make([]SpanData, len(r.spans))
+	copy(spans, r.spans)
+
+	return spans
+}
+
+// Reset discards the recorded spans.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.spans = nil
+	r.mu.Unlock()
+}
+
+type span struct {
+	recorder *Recorder
+	data     SpanData
+}
+
+func (s *span) SetAttributes(attrs ...hyb.Attribute) {
+	for _, a := range attrs {
+		s.data.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *span) End() {
+	s.data.End = time.Now()
+
+	r := s.recorder
+	r.mu.Lock()
+	r.spans = append(r.spans, s.data)
+	r.mu.Unlock()
+}
@@ -0,0 +1,85 @@
+package hybtrace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/robskie/hyb"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildIndex(titles ...string) *hyb.Index {
+	b := hyb.NewBuilder()
+	for i, t := range titles {
+		b.Add(i+1, strings.Fields(t), len(titles)-i)
+	}
+
+	return b.Build()
+}
+
+func TestRecorder(t *testing.T) {
+	idx := buildIndex("star wars", "star trek", "alien", "stargate")
+
+	rec := NewRecorder()
+	idx.SetTracer(rec)
+
+	// The search is a child of the span of the caller
+	ctx, parent := rec.Start(context.Background(), "request")
+	idx.SearchContext(ctx, []string{"star", "t"}, &hyb.Result{})
+	parent.End()
+
+	spans := rec.Spans()
+	byName := map[string][]SpanData{}
+	for _, s := range spans {
+		byName[s.Name] = append(byName[s.Name], s)
+		assert.False(t, s.End.Before(s.Start))
+	}
+
+	request := byName["request"][0]
+	search := byName["hyb.Search"][0]
+	assert.Equal(t, request.ID, search.Parent)
+	assert.Equal(t, 2, search.Attributes["hyb.query.words"])
+	assert.Equal(t, 6, search.Attributes["hyb.query.length"])
+	assert.Equal(t, "full", search.Attributes["hyb.path"])
+	assert.Equal(t, 1, search.Attributes["hyb.results"])
+
+	// One span for each query word, each with
+	// the spans of the phases of the search
+	assert.Len(t, byName["hyb.search"], 2)
+	for _, w := range byName["hyb.search"] {
+		assert.Equal(t, search.ID, w.Parent)
+	}
+
+	for _, name := range []string{"hyb.selectBlocks", "hyb.getWordRange", "hyb.intersect", "hyb.merge"} {
+		assert.NotEmpty(t, byName[name], name)
+		for _, s := range byName[name] {
+			assert.Equal(t, "hyb.search", nameOf(spans, s.Parent), name)
+		}
+	}
+
+	for _, s := range byName["hyb.intersect"] {
+		assert.Contains(t, s.Attributes, "hyb.postings.scanned")
+		assert.Contains(t, s.Attributes, "hyb.postings.matched")
+	}
+
+	merges := byName["hyb.merge"]
+	assert.Equal(t, 1, merges[len(merges)-1].Attributes["hyb.results"])
+
+	rec.Reset()
+	assert.Empty(t, rec.Spans())
+
+	idx.SetTracer(nil)
+	idx.Search([]string{"star"}, &hyb.Result{})
+	assert.Empty(t, rec.Spans())
+}
+
+func nameOf(spans []SpanData, id int) string {
+	for _, s := range spans {
+		if s.ID == id {
+			return s.Name
+		}
+	}
+
+	return ""
+}
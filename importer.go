@@ -0,0 +1,385 @@
+package hyb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is the format of a document stream.
+type Format int
+
+// Supported document stream formats. CSV and TSV
+// streams must start with a header row which names
+// the fields of the records.
+const (
+	JSONL Format = iota
+	CSV
+	TSV
+)
+
+// ImportOptions maps the fields of the records
+// of a document stream to the document ID,
+// keywords and rank.
+type ImportOptions struct {
+	Format Format
+
+	// ID is the name of the field that contains
+	// the document ID. It must be an integer.
+	ID string
+
+	// Keywords are the names of the fields that
+	// contain the document keywords. Text fields
+	// are split into keywords using Tokenize. In
+	// JSON Lines, a field can also be an array of
+	// strings which are used as is.
+	Keywords []string
+
+	// Rank is the name of the field that contains
+	// the document rank. It must be an integer.
+	// If it is empty, all documents have zero rank.
+	Rank string
+
+	// Tokenize splits text into keywords. If it is
+	// nil, strings.Fields is used.
+	Tokenize func(text string) []string
+}
+
+// ImportError is returned when a record of
+// a document stream cannot be imported.
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("hyb: line %d: %v", e.Line, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// Import reads the documents from r and adds them to the
+// builder. Gzip compressed streams are decompressed
+// transparently. It returns the number of documents added.
+// If a record cannot be imported, it stops and returns an
+// *ImportError which contains the line number of the record.
+func (b *Builder) Import(r io.Reader, opts ImportOptions) (int, error) {
+	count := 0
+	err := ImportFunc(r, opts, func(id int, keywords []string, rank int) {
+		b.Add(id, keywords, rank)
+		count++
+	})
+
+	return count, err
+}
+
+// ImportFunc reads the documents from r and calls fn for
+// each of them. It is the same as Builder.Import except that
+// the documents are passed to fn instead of a builder.
+func ImportFunc(
+	r io.Reader,
+	opts ImportOptions,
+	fn func(id int, keywords []string, rank int)) error {
+
+	if opts.ID == "" {
+		return errors.New("hyb: import needs an ID field")
+	}
+
+	if opts.Tokenize == nil {
+		opts.Tokenize = strings.Fields
+	}
+
+	r, err := decompress(r)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case JSONL:
+		return importJSONL(r, opts, fn)
+	case CSV:
+		cr := csv.NewReader(r)
+		cr.ReuseRecord = true
+		return importRecords(cr, opts, fn)
+	case TSV:
+		return importRecords(newTSVReader(r), opts, fn)
+	}
+
+	return fmt.Errorf("hyb: unknown import format %d", opts.Format)
+}
+
+// decompress returns a reader that decompresses
+// r if it starts with the gzip magic number.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("hyb: import failed (%v)", err)
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("hyb: import failed (%v)", err)
+		}
+
+		return zr, nil
+	}
+
+	return br, nil
+}
+
+func importJSONL(
+	r io.Reader,
+	opts ImportOptions,
+	fn func(int, []string, int)) error {
+
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return &ImportError{line, err}
+		}
+
+		if len(bytes.TrimSpace(data)) > 0 {
+			id, keywords, rank, perr := parseJSON(data, opts)
+			if perr != nil {
+				return &ImportError{line, perr}
+			}
+
+			fn(id, keywords, rank)
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func parseJSON(data []byte, opts ImportOptions) (int, []string, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	record := map[string]interface{}{}
+	if err := dec.Decode(&record); err != nil {
+		return 0, nil, 0, err
+	}
+
+	// A line must contain a single record
+	if _, err := dec.Token(); err != io.EOF {
+		return 0, nil, 0, errors.New("unexpected data after record")
+	}
+
+	id, err := jsonInt(record, opts.ID, true)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	rank := 0
+	if opts.Rank != "" {
+		rank, err = jsonInt(record, opts.Rank, false)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+	}
+
+	keywords := []string{}
+	for _, name := range opts.Keywords {
+		switch v := record[name].(type) {
+		case nil:
+		case string:
+			keywords = append(keywords, opts.Tokenize(v)...)
+		case []interface{}:
+			for _, e := range v {
+				s, ok := e.(string)
+				if !ok {
+					return 0, nil, 0, fmt.Errorf("field %q is not a string array", name)
+				}
+				keywords = append(keywords, s)
+			}
+		default:
+			return 0, nil, 0, fmt.Errorf("field %q is not a string or an array", name)
+		}
+	}
+
+	return id, keywords, rank, nil
+}
+
+// jsonInt returns the integer value of a field which
+// can be a number or a string. Missing fields are
+// zero unless they are required.
+func jsonInt(record map[string]interface{}, name string, required bool) (int, error) {
+	var s string
+	switch v := record[name].(type) {
+	case nil:
+		if required {
+			return 0, fmt.Errorf("missing field %q", name)
+		}
+		return 0, nil
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return 0, fmt.Errorf("field %q is not an integer", name)
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("field %q is not an integer", name)
+	}
+
+	return n, nil
+}
+
+// recordReader reads the records of a CSV or TSV stream.
+type recordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+}
+
+func importRecords(
+	rr recordReader,
+	opts ImportOptions,
+	fn func(int, []string, int)) error {
+
+	// Read the header and map the field names
+	header, err := rr.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return recordError(err)
+	}
+	hline, _ := rr.FieldPos(0)
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	column := func(name string) (int, error) {
+		i, ok := columns[name]
+		if !ok {
+			return 0, &ImportError{hline, fmt.Errorf("missing field %q", name)}
+		}
+		return i, nil
+	}
+
+	idcol, err := column(opts.ID)
+	if err != nil {
+		return err
+	}
+
+	rankcol := -1
+	if opts.Rank != "" {
+		if rankcol, err = column(opts.Rank); err != nil {
+			return err
+		}
+	}
+
+	kwcols := make([]int, len(opts.Keywords))
+	for i, name := range opts.Keywords {
+		if kwcols[i], err = column(name); err != nil {
+			return err
+		}
+	}
+
+	for {
+		record, err := rr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return recordError(err)
+		}
+		line, _ := rr.FieldPos(0)
+
+		id, err := strconv.Atoi(strings.TrimSpace(record[idcol]))
+		if err != nil {
+			return &ImportError{line, fmt.Errorf("field %q is not an integer", opts.ID)}
+		}
+
+		rank := 0
+		if rankcol >= 0 {
+			rank, err = strconv.Atoi(strings.TrimSpace(record[rankcol]))
+			if err != nil {
+				return &ImportError{line, fmt.Errorf("field %q is not an integer", opts.Rank)}
+			}
+		}
+
+		keywords := []string{}
+		for _, c := range kwcols {
+			keywords = append(keywords, opts.Tokenize(record[c])...)
+		}
+
+		fn(id, keywords, rank)
+	}
+}
+
+// recordError converts a parse error to an *ImportError.
+func recordError(err error) error {
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return &ImportError{perr.Line, perr.Err}
+	}
+
+	var ierr *ImportError
+	if errors.As(err, &ierr) {
+		return ierr
+	}
+
+	return fmt.Errorf("hyb: import failed (%v)", err)
+}
+
+// tsvReader reads tab-separated values. Unlike CSV,
+// fields are not quoted and cannot contain tabs or
+// newlines.
+type tsvReader struct {
+	r       *bufio.Reader
+	line    int
+	nfields int
+}
+
+func newTSVReader(r io.Reader) *tsvReader {
+	return &tsvReader{r: bufio.NewReader(r), nfields: -1}
+}
+
+func (t *tsvReader) Read() ([]string, error) {
+	for {
+		data, err := t.r.ReadString('\n')
+		if err != nil && (err != io.EOF || len(data) == 0) {
+			return nil, err
+		}
+		t.line++
+
+		data = strings.TrimRight(data, "\r\n")
+		if len(data) == 0 {
+			continue
+		}
+
+		record := strings.Split(data, "\t")
+		if t.nfields < 0 {
+			t.nfields = len(record)
+		} else if len(record) != t.nfields {
+			return nil, &ImportError{t.line, fmt.Errorf(
+				"wrong number of fields (%d instead of %d)",
+				len(record),
+				t.nfields,
+			)}
+		}
+
+		return record, nil
+	}
+}
+
+func (t *tsvReader) FieldPos(field int) (int, int) {
+	return t.line, 0
+}
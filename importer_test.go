@@ -0,0 +1,210 @@
+package hyb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type idoc struct {
+	id       int
+	keywords []string
+	rank     int
+}
+
+func importDocs(input string, opts ImportOptions) ([]idoc, error) {
+	docs := []idoc{}
+	err := ImportFunc(strings.NewReader(input), opts, func(id int, kw []string, rank int) {
+		docs = append(docs, idoc{id, kw, rank})
+	})
+
+	return docs, err
+}
+
+func TestImport(t *testing.T) {
+	expected := []idoc{
+		{1, []string{"star", "wars", "lucas"}, 10},
+		{2, []string{"star", "trek", "abrams"}, 5},
+		{3, []string{"alien"}, 0},
+	}
+
+	tests := []struct {
+		input string
+		opts  ImportOptions
+	}{
+		{
+			`{"id": 1, "title": "star wars", "director": "lucas", "rank": 10}
+{"id": "2", "title": "star trek", "director": ["abrams"], "rank": 5}
+
+{"id": 3, "title": "alien"}
+`,
+			ImportOptions{JSONL, "id", []string{"title", "director"}, "rank", nil},
+		},
+		{
+			"id,title,director,rank\n" +
+				"1,star wars,lucas,10\n" +
+				"2,\"star trek\",abrams,5\n" +
+				"3,alien,,0",
+			ImportOptions{CSV, "id", []string{"title", "director"}, "rank", nil},
+		},
+		{
+			"rank\ttitle\tid\tdirector\n" +
+				"10\tstar wars\t1\tlucas\n" +
+				"5\tstar trek\t2\tabrams\r\n" +
+				"\n" +
+				"0\talien\t3\t\n",
+			ImportOptions{TSV, "id", []string{"title", "director"}, "rank", nil},
+		},
+	}
+
+	for _, tt := range tests {
+		docs, err := importDocs(tt.input, tt.opts)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, docs)
+
+		// Gzip compressed stream
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		zw.Write([]byte(tt.input))
+		zw.Close()
+
+		docs = []idoc{}
+		err = ImportFunc(buf, tt.opts, func(id int, kw []string, rank int) {
+			docs = append(docs, idoc{id, kw, rank})
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, expected, docs)
+	}
+
+	// Custom tokenizer
+	opts := ImportOptions{
+		Format:   CSV,
+		ID:       "id",
+		Keywords: []string{"title"},
+		Tokenize: func(s string) []string {
+			return strings.Fields(strings.ToLower(s))
+		},
+	}
+	docs, err := importDocs("id,title\n7,Star Wars", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []idoc{{7, []string{"star", "wars"}, 0}}, docs)
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  ImportOptions
+		line  int
+	}{
+		{
+			"{\"id\": 1}\n{\"id\": 2\n",
+			ImportOptions{Format: JSONL, ID: "id"},
+			2,
+		},
+		{
+			"{\"id\": 1}\n\n{\"title\": \"x\"}\n",
+			ImportOptions{Format: JSONL, ID: "id"},
+			3,
+		},
+		{
+			"{\"id\": 1}\n{\"id\": 2}{\"id\": 3}\n",
+			ImportOptions{Format: JSONL, ID: "id"},
+			2,
+		},
+		{
+			"{\"id\": 1} garbage\n",
+			ImportOptions{Format: JSONL, ID: "id"},
+			1,
+		},
+		{
+			"{\"id\": 1, \"rank\": \"high\"}\n",
+			ImportOptions{Format: JSONL, ID: "id", Rank: "rank"},
+			1,
+		},
+		{
+			"{\"id\": 1, \"title\": 5}\n",
+			ImportOptions{Format: JSONL, ID: "id", Keywords: []string{"title"}},
+			1,
+		},
+		{
+			"id,title\n1,a\n2,\"b\n",
+			ImportOptions{Format: CSV, ID: "id", Keywords: []string{"title"}},
+			3,
+		},
+		{
+			"id,title\n1,a\n2,b,c\n",
+			ImportOptions{Format: CSV, ID: "id", Keywords: []string{"title"}},
+			3,
+		},
+		{
+			"id,title\n1,a\nx,b\n",
+			ImportOptions{Format: CSV, ID: "id", Keywords: []string{"title"}},
+			3,
+		},
+		{
+			"id,title\n1,a\n",
+			ImportOptions{Format: CSV, ID: "id", Keywords: []string{"name"}},
+			1,
+		},
+		{
+			"\n\nid,title\n1,a\n",
+			ImportOptions{Format: CSV, ID: "id", Keywords: []string{"name"}},
+			3,
+		},
+		{
+			"\nid\ttitle\n1\ta\n",
+			ImportOptions{Format: TSV, ID: "key", Keywords: []string{"title"}},
+			2,
+		},
+		{
+			"id\ttitle\n1\ta\n\n2\tb\tc\n",
+			ImportOptions{Format: TSV, ID: "id", Keywords: []string{"title"}},
+			4,
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := importDocs(tt.input, tt.opts)
+
+		var ierr *ImportError
+		if assert.True(t, errors.As(err, &ierr), tt.input) {
+			assert.Equal(t, tt.line, ierr.Line, tt.input)
+		}
+	}
+
+	_, err := importDocs("", ImportOptions{Format: JSONL})
+	assert.NotNil(t, err)
+
+	_, err = importDocs("", ImportOptions{Format: Format(-1), ID: "id"})
+	assert.NotNil(t, err)
+}
+
+func TestBuilderImport(t *testing.T) {
+	input := "id,title,rank\n" +
+		"1,star wars,10\n" +
+		"2,star trek,5\n" +
+		"3,alien,7\n"
+
+	b := NewBuilder()
+	n, err := b.Import(strings.NewReader(input), ImportOptions{
+		Format:   CSV,
+		ID:       "id",
+		Keywords: []string{"title"},
+		Rank:     "rank",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	res := &Result{}
+	b.Build().Search([]string{"star"}, res)
+
+	ids := []int{}
+	for it := res.Hits(); it.Next(); {
+		ids = append(ids, it.ID())
+	}
+	assert.Equal(t, []int{1, 2}, ids)
+}
@@ -0,0 +1,213 @@
+package hyb
+
+import "sort"
+
+// BlockStrategy selects where the blocks of an index are cut.
+type BlockStrategy int
+
+// Block strategies.
+const (
+	// PrefixBlocks cuts blocks only between words that
+	// have different prefixes, so that a query prefix
+	// selects as few blocks as possible. This is the
+	// default.
+	PrefixBlocks BlockStrategy = iota
+
+	// EvenBlocks cuts a block as soon as it reaches
+	// the block size, which gives blocks of the same
+	// size regardless of the prefixes of the words.
+	EvenBlocks
+
+	// CustomBlocks starts a block at each of the
+	// given boundary words. See OptimizeLayout.
+	CustomBlocks
+)
+
+func (s BlockStrategy) String() string {
+	switch s {
+	case PrefixBlocks:
+		return "prefix"
+	case EvenBlocks:
+		return "even"
+	case CustomBlocks:
+		return "custom"
+	}
+
+	return "unknown"
+}
+
+// bp128BlockSize is the number of integers
+// that bp128 packs together.
+const bp128BlockSize = 128
+
+// BuilderOptions configures the layout of the
+// blocks of an index and the order of documents
+// with equal ranks. Zero values are replaced by
+// their defaults.
+type BuilderOptions struct {
+	// NumBlocks is the nominal number of blocks.
+	// It defaults to 5. Large indexes need more
+	// blocks to reduce merging while searching
+	// and small ones need fewer.
+	NumBlocks int
+
+	// BlockSize is the target number of postings
+	// of a block. If it is set, it is used instead
+	// of NumBlocks.
+	BlockSize int
+
+	// ChunkSize is the maximum number of postings of
+	// a compressed chunk. It is rounded up to a multiple
+	// of 128 and defaults to 2048. Smaller chunks can be
+	// skipped more often when intersecting, larger ones
+	// compress better.
+	ChunkSize int
+
+	// Strategy selects where the blocks are cut.
+	Strategy BlockStrategy
+
+	// PrefixLen is the length of the prefixes used
+	// by PrefixBlocks. If it is zero, the shortest
+	// length that gives at least NumBlocks blocks
+	// is used.
+	PrefixLen int
+
+	// Boundaries are the words where the blocks
+	// start when using CustomBlocks. A block starts
+	// at the first word that is greater than or
+	// equal to its boundary.
+	Boundaries []string
+
+	// TieBreak orders the documents that have
+	// the same rank. See Builder.SetTieBreak.
+	// Since its zero value is the default policy,
+	// SetOptions keeps the policy of the builder
+	// if it is zero.
+	TieBreak TieBreak
+}
+
+// SetOptions sets the block layout and the tie-break
+// policy of the index. If opts.TieBreak is zero, the
+// policy set by SetTieBreak is kept, so the two can be
+// called in any order. The options used are recorded in
+// the index and can be read back with Index.Layout.
+func (b *Builder) SetOptions(opts BuilderOptions) {
+	if opts.TieBreak == DescendingID {
+		opts.TieBreak = b.opts.TieBreak
+	}
+	b.opts = opts
+}
+
+// layout returns the options with their defaults
+// given the total number of postings.
+func (o BuilderOptions) layout(npostings int) BuilderOptions {
+	if o.NumBlocks <= 0 {
+		o.NumBlocks = numBlocks
+	}
+
+	if o.BlockSize <= 0 {
+		o.BlockSize = (npostings / o.NumBlocks) + 1
+	} else {
+		o.NumBlocks = (npostings / o.BlockSize) + 1
+	}
+
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = postingsChunkSize
+	}
+	o.ChunkSize = (o.ChunkSize + bp128BlockSize - 1) / bp128BlockSize * bp128BlockSize
+
+	o.PrefixLen = max(o.PrefixLen, 0)
+
+	return o
+}
+
+// Layout returns the block layout options
+// and the tie-break policy used to build
+// the index.
+func (idx *Index) Layout() BuilderOptions {
+	return idx.layout
+}
+
+// chunkSize returns the maximum
+// number of postings of a chunk.
+func (idx *Index) chunkSize() int {
+	if idx.layout.ChunkSize <= 0 {
+		return postingsChunkSize
+	}
+
+	return idx.layout.ChunkSize
+}
+
+// createEvenBlocks creates blocks of about
+// blockSize postings in word order.
+func createEvenBlocks(blockSize int, freqs []int) []block {
+	blocks := []block{}
+
+	b := block{}
+	for i, f := range freqs {
+		if b.length >= blockSize {
+			b.boundary[1] = i - 1
+			blocks = append(blocks, b)
+
+			b = block{}
+			b.boundary[0] = i
+		}
+
+		b.length += f
+	}
+
+	b.boundary[1] = len(freqs) - 1
+	blocks = append(blocks, b)
+
+	return blocks
+}
+
+// createCustomBlocks creates blocks that
+// start at the given boundary words.
+func createCustomBlocks(boundaries []string, words []string, freqs []int) []block {
+	sorted := make([]string, len(boundaries))
+	copy(sorted, boundaries)
+	sort.Strings(sorted)
+
+	starts := []int{0}
+	for _, w := range sorted {
+		i := sort.SearchStrings(words, w)
+		if i > starts[len(starts)-1] && i < len(words) {
+			starts = append(starts, i)
+		}
+	}
+	starts = append(starts, len(words))
+
+	blocks := make([]block, len(starts)-1)
+	for i := range blocks {
+		b := &blocks[i]
+		b.boundary = [2]int{starts[i], starts[i+1] - 1}
+		for _, f := range freqs[starts[i]:starts[i+1]] {
+			b.length += f
+		}
+	}
+
+	return blocks
+}
+
+// blockMap returns a function that maps a word
+// to the index of the block that contains it.
+func blockMap(blk []block) func(int) int {
+	cblk := make([]block, len(blk))
+	copy(cblk, blk)
+	for i := range cblk {
+		cblk[i].index = i
+	}
+
+	sort.Sort(byLen(cblk))
+	return func(w int) int {
+		for _, b := range cblk {
+			if w >= b.boundary[0] && w <= b.boundary[1] {
+				return b.index
+			}
+		}
+
+		// Should not reach here
+		return -1
+	}
+}
@@ -0,0 +1,91 @@
+package hyb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilderOptions(t *testing.T) {
+	index, docs := createIndex("files/books.txt.gz")
+
+	layouts := []BuilderOptions{
+		{NumBlocks: 1},
+		{NumBlocks: 50},
+		{BlockSize: 1000, ChunkSize: 100},
+		{PrefixLen: 1, ChunkSize: 128},
+		{Strategy: EvenBlocks, NumBlocks: 20, ChunkSize: 4096},
+	}
+
+	for _, layout := range layouts {
+		b := NewBuilder()
+		b.SetOptions(layout)
+		for i, d := range docs {
+			b.Add(i, strings.Fields(d), len(docs)-i)
+		}
+		lindex := b.Build()
+
+		// The layout is recorded in the index
+		buf := &bytes.Buffer{}
+		assert.Nil(t, lindex.Write(buf))
+		rindex := NewIndex()
+		assert.Nil(t, rindex.Read(buf))
+
+		l := rindex.Layout()
+		assert.Equal(t, layout.Strategy, l.Strategy)
+		assert.Equal(t, 0, l.ChunkSize%128)
+		assert.True(t, l.ChunkSize >= layout.ChunkSize)
+		if layout.NumBlocks > 0 {
+			assert.Equal(t, layout.NumBlocks, l.NumBlocks)
+		}
+		if layout.BlockSize > 0 {
+			assert.Equal(t, layout.BlockSize, l.BlockSize)
+		}
+
+		s := rindex.Stats()
+		for _, bs := range s.Blocks {
+			assert.True(t, bs.Chunks >= (bs.Postings+l.ChunkSize-1)/l.ChunkSize)
+			if layout.Strategy == EvenBlocks {
+				assert.True(t, bs.Postings < 2*l.BlockSize+1000)
+			}
+		}
+		if layout.NumBlocks == 1 {
+			assert.Len(t, s.Blocks, 1)
+		}
+
+		// Search results are the same
+		// regardless of the layout
+		for _, d := range docs[:20] {
+			expected := &Result{}
+			res := &Result{}
+			for _, q := range substrings(d) {
+				query := strings.Fields(q)
+				index.Search(query, expected)
+				rindex.Search(query, res)
+
+				assert.Equal(t, hitIDs(expected.TopHits(10)), hitIDs(res.TopHits(10)), q)
+				assert.Equal(t, expected.Completions(), res.Completions(), q)
+			}
+		}
+	}
+}
+
+func TestCreateEvenBlocks(t *testing.T) {
+	blocks := createEvenBlocks(5, []int{3, 3, 1, 6, 2, 2})
+	assert.Equal(t, []block{
+		{boundary: [2]int{0, 1}, length: 6},
+		{boundary: [2]int{2, 3}, length: 7},
+		{boundary: [2]int{4, 5}, length: 4},
+	}, blocks)
+}
+
+func hitIDs(hits *Hits) []int {
+	ids := []int{}
+	for hits.Next() {
+		ids = append(ids, hits.ID())
+	}
+
+	return ids
+}
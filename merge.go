@@ -0,0 +1,132 @@
+package hyb
+
+import (
+	"math"
+	"sort"
+
+	"github.com/robskie/bp128"
+)
+
+// MergeIndexes creates an index that contains the
+// documents of a and b except those whose IDs are in
+// deletes. If a document is in both indexes, the one
+// in b is kept. The documents are decoded from the
+// postings and the phrases of the indexes, so neither
+// index needs its original documents.
+//
+// Since an index only keeps the order of the ranks,
+// the ranks of each index are scaled to the same range
+// before they are merged. Ties are broken by the
+// tie-break policy of b, where the documents of a are
+// added before those of b.
+// Phrases are only kept if they are in the phrase
+// table of either index, and the query log popularity
+// of a word is the highest of the two indexes. The
+// block layout is the same as b.
+func MergeIndexes(a, b *Index, deletes []int) *Index {
+	// Keep the layout of b but let the
+	// block size follow the merged size
+	layout := b.Layout()
+	layout.BlockSize = 0
+
+	builder := NewBuilder()
+	builder.SetOptions(layout)
+	for _, idx := range []*Index{a, b} {
+		for _, d := range idx.docs() {
+			d.count = builder.count
+			builder.add(d)
+		}
+	}
+
+	for _, id := range deletes {
+		builder.Delete(id)
+	}
+
+	idx := builder.Build()
+	if a.wordpop == nil && b.wordpop == nil {
+		return idx
+	}
+
+	// Merge the query log popularity
+	wordpop := map[string]uint32{}
+	for _, src := range []*Index{a, b} {
+		for i, p := range src.wordpop {
+			w := src.words[i]
+			wordpop[w] = uint32(max(int(wordpop[w]), int(p)))
+		}
+	}
+
+	idx.wordpop = make([]uint32, len(idx.words))
+	for i, w := range idx.words {
+		idx.wordpop[i] = wordpop[w]
+	}
+	idx.size += 4 * len(idx.wordpop)
+
+	return idx
+}
+
+// scaleRank converts a rank of an index whose
+// greatest rank is maxRank to the range of uint32.
+func scaleRank(rank uint32, maxRank int) uint32 {
+	if maxRank <= 0 {
+		return math.MaxUint32
+	}
+
+	return uint32(float64(rank) / float64(maxRank) * math.MaxUint32)
+}
+
+// docs decodes the documents of the index from its
+// postings and phrases. The documents are sorted
+// by ID and their ranks are scaled to uint32.
+func (idx *Index) docs() []doc {
+	docs := []doc{}
+	index := map[uint32]int{}
+
+	buffer := []uint32{}
+	chunkSize := idx.chunkSize()
+	bp128.MakeAlignedSlice(chunkSize*3, &buffer)
+
+	ids := buffer[0:]
+	words := ids[chunkSize:]
+	ranks := words[chunkSize:]
+
+	for _, b := range idx.blocks {
+		for _, p := range b.posts {
+			bp128.Unpack(p.ids, &ids)
+			bp128.Unpack(p.words, &words)
+			bp128.Unpack(p.ranks, &ranks)
+
+			for j, id := range ids {
+				i, ok := index[id]
+				if !ok {
+					i = len(docs)
+					index[id] = i
+
+					rank := scaleRank(ranks[j], idx.maxRank)
+					docs = append(docs, doc{id: int(id), rank: int(rank)})
+				}
+
+				word := idx.words[idx.freqword[words[j]]]
+				docs[i].words = append(docs[i].words, word)
+			}
+		}
+	}
+
+	// Phrases are sorted, so the
+	// phrases of each doc are too
+	t := &idx.phrases
+	for i, p := range t.phrases {
+		for _, id := range t.ids[t.offsets[i]:t.offsets[i+1]] {
+			if j, ok := index[id]; ok {
+				docs[j].phrases = append(docs[j].phrases, p)
+			}
+		}
+	}
+
+	for _, d := range docs {
+		sort.Strings(d.words)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].id < docs[j].id })
+
+	return docs
+}
@@ -0,0 +1,129 @@
+package hyb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeIndexes(t *testing.T) {
+	_, docs := createIndex("files/books.txt.gz")
+
+	// Split the documents between two indexes
+	// and compare their merge to a fresh build
+	ba := NewBuilder()
+	bb := NewBuilder()
+	bfresh := NewBuilder()
+	for i, d := range docs {
+		if i%2 == 0 {
+			ba.Add(i, strings.Fields(d), i)
+		} else {
+			bb.Add(i, strings.Fields(d), i)
+		}
+		bfresh.Add(i, strings.Fields(d), i)
+	}
+
+	merged := MergeIndexes(ba.Build(), bb.Build(), nil)
+	fresh := bfresh.Build()
+
+	assert.Equal(t, fresh.words, merged.words)
+	assert.Equal(t, fresh.freqword, merged.freqword)
+	assert.Equal(t, fresh.ndocs, merged.ndocs)
+	assert.Equal(t, parseIndex(fresh), parseIndex(merged))
+
+	for _, d := range docs[:20] {
+		res := &Result{}
+		expected := &Result{}
+		for _, q := range substrings(d) {
+			merged.Search(strings.Fields(q), res)
+			fresh.Search(strings.Fields(q), expected)
+
+			assert.Equal(t, expected.TopHits(10), res.TopHits(10), q)
+			assert.Equal(t, expected.TopCompletions(5), res.TopCompletions(5), q)
+		}
+	}
+}
+
+func TestMergeIndexesEqualRanks(t *testing.T) {
+	_, docs := createIndex("files/books.txt.gz")
+
+	// Both indexes have documents of both ranks
+	rank := func(id int) int { return (id / 2) % 2 }
+
+	for _, ties := range []TieBreak{DescendingID, KeepTies} {
+		ba := NewBuilder()
+		bb := NewBuilder()
+		bfresh := NewBuilder()
+		for _, b := range []*Builder{ba, bb, bfresh} {
+			b.SetTieBreak(ties)
+		}
+
+		for i, d := range docs {
+			if i%2 == 0 {
+				ba.Add(i, strings.Fields(d), rank(i))
+			} else {
+				bb.Add(i, strings.Fields(d), rank(i))
+			}
+			bfresh.Add(i, strings.Fields(d), rank(i))
+		}
+
+		merged := MergeIndexes(ba.Build(), bb.Build(), nil)
+		fresh := bfresh.Build()
+		assert.Equal(t, ties, merged.Layout().TieBreak)
+
+		for _, d := range docs[:20] {
+			res := &Result{}
+			expected := &Result{}
+			for _, q := range substrings(d) {
+				merged.Search(strings.Fields(q), res)
+				fresh.Search(strings.Fields(q), expected)
+
+				// Documents of a higher rank come first
+				ids := hitIDs(res.Hits())
+				for j := 1; j < len(ids); j++ {
+					assert.True(t, rank(ids[j-1]) >= rank(ids[j]), q)
+				}
+
+				// Kept ties are ordered by ID like a fresh build
+				if ties == KeepTies {
+					assert.Equal(t, hitIDs(expected.Hits()), ids, q)
+				}
+			}
+		}
+	}
+}
+
+func TestMergeIndexesOverride(t *testing.T) {
+	ba := NewBuilder()
+	ba.EnablePhrases(2, 1)
+	ba.Add(1, []string{"star", "wars"}, 3)
+	ba.Add(2, []string{"alien"}, 2)
+	ba.Add(3, []string{"star", "trek"}, 1)
+
+	bb := NewBuilder()
+	bb.EnablePhrases(2, 1)
+	bb.AddQuery("dune", 5)
+	bb.Add(3, []string{"stargate"}, 1)
+	bb.Add(4, []string{"dune"}, 2)
+
+	merged := MergeIndexes(ba.Build(), bb.Build(), []int{2})
+
+	pindex := parseIndex(merged)
+	assert.Len(t, pindex, 3)
+	assert.Equal(t, []string{"star", "wars"}, pindex[1].words)
+	assert.Equal(t, []string{"stargate"}, pindex[3].words)
+	assert.Equal(t, []string{"dune"}, pindex[4].words)
+
+	// Phrases of replaced documents are dropped
+	assert.Equal(t, []string{"star wars"}, merged.phrases.phrases)
+	assert.Equal(t, []uint32{1}, merged.phrases.ids)
+
+	// Query log popularity is kept
+	assert.Equal(t, []string{"dune", "star", "stargate", "wars"}, merged.words)
+	assert.True(t, merged.wordpop[0] > 0)
+	assert.Equal(t, uint32(0), merged.wordpop[1])
+
+	empty := MergeIndexes(NewIndex(), NewIndex(), nil)
+	assert.Equal(t, 0, empty.Size())
+}
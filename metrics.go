@@ -0,0 +1,83 @@
+package hyb
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Metrics receives measurements from indexes and builders,
+// for example, to export them to a monitoring system. The
+// hybmetrics package has expvar and Prometheus versions.
+// It must be safe for concurrent use.
+type Metrics interface {
+	// ObserveSearch is called after each Index.Search.
+	ObserveSearch(m SearchMetrics)
+
+	// ObserveBuild is called after each successful
+	// Builder.Build.
+	ObserveBuild(m BuildMetrics)
+}
+
+// SearchMetrics contains the measurements of a search.
+type SearchMetrics struct {
+	// QueryLen is the number of characters of the
+	// query including the spaces between its words.
+	QueryLen int
+	Words    int
+
+	Path SearchPath
+
+	// Scanned is the number of postings unpacked
+	// and Results is the number of postings of
+	// the result.
+	Scanned int
+	Results int
+
+	// CacheHits and CacheMisses are the number of query
+	// words that were and were not in the search cache.
+	CacheHits   int
+	CacheMisses int
+
+	Duration time.Duration
+}
+
+// BuildMetrics contains the measurements of a build.
+type BuildMetrics struct {
+	Documents int
+	Words     int
+	Postings  int
+	Size      int
+
+	Duration time.Duration
+}
+
+// SetMetrics sets where the measurements of the
+// searches are sent. Passing nil disables them,
+// which is the default. It must be called before
+// searching the index.
+func (idx *Index) SetMetrics(m Metrics) {
+	idx.metrics = m
+}
+
+// SetMetrics sets where the measurements of the builds
+// are sent. The built indexes send the measurements of
+// their searches there too. See Index.SetMetrics.
+func (b *Builder) SetMetrics(m Metrics) {
+	b.metrics = m
+}
+
+// observe sends the measurements of the
+// search of r that started at the given time.
+func (idx *Index) observe(query []string, r *Result, start time.Time) {
+	idx.metrics.ObserveSearch(SearchMetrics{
+		QueryLen:    utf8.RuneCountInString(strings.Join(query, " ")),
+		Words:       len(query),
+		Path:        r.path,
+		Scanned:     r.scanned,
+		Results:     len(r.results),
+		CacheHits:   r.cacheHits,
+		CacheMisses: r.cacheMisses,
+		Duration:    time.Since(start),
+	})
+}
@@ -0,0 +1,360 @@
+package hyb
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/robskie/bp128"
+)
+
+const (
+	// mergeCost is the cost of merging a posting
+	// relative to the cost of scanning one.
+	mergeCost = 2.0
+
+	// maxLayoutUnits is the maximum number of word
+	// groups whose boundaries are considered as
+	// block boundaries by OptimizeLayout.
+	maxLayoutUnits = 512
+
+	// maxLayoutBlocks is the maximum number
+	// of blocks considered by OptimizeLayout.
+	maxLayoutBlocks = 128
+)
+
+// LayoutCost is the cost of searching a set of
+// prefixes as predicted by the cost model of
+// OptimizeLayout.
+type LayoutCost struct {
+	// Scanned is the average number of postings
+	// unpacked per prefix.
+	Scanned float64
+
+	// Merged is the average number of postings
+	// per prefix multiplied by the number of
+	// additional blocks they are merged from.
+	Merged float64
+
+	// Size is the estimated size of
+	// the postings in bytes.
+	Size int
+}
+
+// Total returns the cost of scanning and merging.
+func (c LayoutCost) Total() float64 {
+	return c.Scanned + mergeCost*c.Merged
+}
+
+// LayoutPlan is a block layout chosen by OptimizeLayout.
+type LayoutPlan struct {
+	// Options builds an index with the planned blocks.
+	Options BuilderOptions
+
+	// Predicted is the cost of the planned blocks and
+	// Current is the cost of the blocks of the index
+	// the plan was made from.
+	Predicted LayoutCost
+	Current   LayoutCost
+}
+
+// SearchCost is the measured cost of searching a set of prefixes.
+type SearchCost struct {
+	// Scanned is the average number of
+	// postings unpacked per prefix.
+	Scanned float64
+
+	// Time is the average search time per prefix.
+	Time time.Duration
+}
+
+// OptimizeLayout picks the block boundaries that minimize
+// the expected cost of searching the sample prefixes, for
+// example, all the prefixes of the words of popular queries.
+// Searching a prefix scans every block that contains one of
+// its completions and merges the postings of those blocks.
+// Fewer, larger blocks need less merging but more scanning.
+// More blocks make the index larger since the document IDs
+// of each block are further apart. If maxSize is positive,
+// the estimated size of the postings is kept within maxSize
+// bytes if possible.
+//
+// To use the plan, build the index again with its options.
+// MeasureCost gives the actual cost of the new index.
+func OptimizeLayout(idx *Index, prefixes []string, maxSize int) *LayoutPlan {
+	plan := &LayoutPlan{Options: idx.Layout()}
+	plan.Options.Strategy = CustomBlocks
+	plan.Options.BlockSize = 0
+	plan.Options.Boundaries = nil
+
+	m := newLayoutModel(idx, prefixes)
+	if len(m.words) == 0 {
+		return plan
+	}
+
+	// Current blocks
+	starts := make([]int, len(idx.blocks))
+	for i, b := range idx.blocks {
+		starts[i] = b.boundary[0]
+	}
+	plan.Current = m.cost(starts)
+
+	// Choose the cheapest partition of the units
+	// into blocks among those that fit in maxSize
+	starts = []int{0}
+	best := m.cost(starts)
+	for _, s := range m.partitions() {
+		c := m.cost(s)
+		if maxSize > 0 && c.Size > maxSize {
+			continue
+		}
+
+		if c.Total() < best.Total() {
+			best = c
+			starts = s
+		}
+	}
+
+	plan.Predicted = best
+	plan.Options.NumBlocks = len(starts)
+	for _, s := range starts[1:] {
+		plan.Options.Boundaries = append(plan.Options.Boundaries, m.words[s])
+	}
+
+	return plan
+}
+
+// MeasureCost searches each prefix in a new
+// result and returns the average cost.
+func MeasureCost(idx *Index, prefixes []string) SearchCost {
+	cost := SearchCost{}
+	if len(prefixes) == 0 {
+		return cost
+	}
+
+	var elapsed time.Duration
+	scanned := 0
+	for _, p := range prefixes {
+		res := &Result{}
+
+		start := time.Now()
+		idx.Search([]string{p}, res)
+		elapsed += time.Since(start)
+
+		scanned += res.scanned
+	}
+
+	n := len(prefixes)
+	cost.Scanned = float64(scanned) / float64(n)
+	cost.Time = elapsed / time.Duration(n)
+
+	return cost
+}
+
+// layoutModel predicts the cost of searching
+// a set of prefixes given the blocks of an index.
+type layoutModel struct {
+	words []string
+	ndocs int
+
+	// sums[i] is the number of
+	// postings of the first i words.
+	sums []int
+
+	// ranges are the word ranges
+	// of the prefixes with results.
+	ranges   [][2]int
+	nqueries int
+
+	// units[i] is the first word of the ith group
+	// of words that the optimizer keeps together.
+	units []int
+}
+
+func newLayoutModel(idx *Index, prefixes []string) *layoutModel {
+	m := &layoutModel{
+		words:    idx.words,
+		ndocs:    max(idx.ndocs, 1),
+		nqueries: max(len(prefixes), 1),
+	}
+
+	freqs := idx.wordFreqs()
+	m.sums = make([]int, len(freqs)+1)
+	for i, f := range freqs {
+		m.sums[i+1] = m.sums[i] + f
+	}
+
+	for _, p := range prefixes {
+		if r := getWordRange(p, idx.words, 0); r != nil {
+			m.ranges = append(m.ranges, [2]int{int(r[0]), int(r[1])})
+		}
+	}
+
+	// Group the words into units of
+	// about the same number of postings
+	total := m.sums[len(freqs)]
+	target := max(total/maxLayoutUnits, 1)
+	for i := range freqs {
+		if len(m.units) == 0 || m.sums[i]-m.sums[m.units[len(m.units)-1]] >= target {
+			m.units = append(m.units, i)
+		}
+	}
+
+	return m
+}
+
+// cost returns the cost of the blocks
+// that start at the given words.
+func (m *layoutModel) cost(starts []int) LayoutCost {
+	ends := make([]int, len(starts))
+	for i := range starts {
+		ends[i] = len(m.words)
+		if i+1 < len(starts) {
+			ends[i] = starts[i+1]
+		}
+	}
+
+	c := LayoutCost{}
+	for _, r := range m.ranges {
+		// Blocks that overlap the range
+		first := sort.SearchInts(starts, r[0]+1) - 1
+		last := sort.SearchInts(starts, r[1]+1) - 1
+
+		scanned := m.sums[ends[last]] - m.sums[starts[first]]
+		out := m.sums[r[1]+1] - m.sums[r[0]]
+
+		c.Scanned += float64(scanned)
+		c.Merged += float64(out * (last - first))
+	}
+	c.Scanned /= float64(m.nqueries)
+	c.Merged /= float64(m.nqueries)
+
+	// Estimate the bits per posting of each stream.
+	// The IDs are delta encoded, so their size
+	// depends on the number of postings per block.
+	bits := func(n float64) float64 { return math.Log2(n+1) + 1 }
+	wbits := bits(float64(len(m.words)))
+	rbits := bits(float64(m.ndocs))
+
+	size := 0.0
+	for i := range starts {
+		n := float64(m.sums[ends[i]] - m.sums[starts[i]])
+		if n > 0 {
+			size += n * (bits(float64(m.ndocs)/n) + wbits + rbits) / 8
+		}
+	}
+	c.Size = int(size)
+
+	return c
+}
+
+// partitions returns the cheapest partition of the units
+// into k blocks for every k up to maxLayoutBlocks. Each
+// partition is given as the first words of its blocks.
+func (m *layoutModel) partitions() [][]int {
+	nunits := len(m.units)
+	unitOf := func(w int) int {
+		return sort.SearchInts(m.units, w+1) - 1
+	}
+	start := func(u int) int {
+		if u == nunits {
+			return len(m.words)
+		}
+		return m.units[u]
+	}
+
+	// A block of units [i, j) is scanned by the queries
+	// that neither end before i nor start at or after j.
+	// A cut before unit c merges the postings of the
+	// queries that start before c and end at or after c.
+	endsBefore := make([]int, nunits+1)
+	startsFrom := make([]int, nunits+2)
+	cuts := make([]float64, nunits+1)
+	for _, r := range m.ranges {
+		qs, qe := unitOf(r[0]), unitOf(r[1])
+		endsBefore[qe+1]++
+		startsFrom[qs]++
+
+		out := float64(m.sums[r[1]+1] - m.sums[r[0]])
+		cuts[qs+1] += out
+		cuts[qe+1] -= out
+	}
+	for i := 1; i <= nunits; i++ {
+		endsBefore[i] += endsBefore[i-1]
+		cuts[i] += cuts[i-1]
+	}
+	for i := nunits - 1; i >= 0; i-- {
+		startsFrom[i] += startsFrom[i+1]
+	}
+
+	nranges := len(m.ranges)
+	blockCost := func(i, j int) float64 {
+		n := nranges - endsBefore[i] - startsFrom[j]
+		return float64(n) * float64(m.sums[start(j)]-m.sums[start(i)])
+	}
+
+	// cost[k][j] is the cost of splitting
+	// the first j units into k blocks.
+	kmax := min(nunits, maxLayoutBlocks)
+	cost := make([][]float64, kmax+1)
+	from := make([][]int, kmax+1)
+	for k := range cost {
+		cost[k] = make([]float64, nunits+1)
+		from[k] = make([]int, nunits+1)
+		for j := range cost[k] {
+			cost[k][j] = math.Inf(1)
+		}
+	}
+	cost[0][0] = 0
+
+	for k := 1; k <= kmax; k++ {
+		for j := k; j <= nunits; j++ {
+			for i := k - 1; i < j; i++ {
+				if math.IsInf(cost[k-1][i], 1) {
+					continue
+				}
+
+				c := cost[k-1][i] + blockCost(i, j)
+				if i > 0 {
+					c += mergeCost * cuts[i]
+				}
+
+				if c < cost[k][j] {
+					cost[k][j] = c
+					from[k][j] = i
+				}
+			}
+		}
+	}
+
+	partitions := make([][]int, 0, kmax)
+	for k := 1; k <= kmax; k++ {
+		starts := make([]int, k)
+		for j, kk := nunits, k; kk > 0; kk-- {
+			i := from[kk][j]
+			starts[kk-1] = start(i)
+			j = i
+		}
+		partitions = append(partitions, starts)
+	}
+
+	return partitions
+}
+
+// wordFreqs returns the number of postings of each word.
+func (idx *Index) wordFreqs() []int {
+	freqs := make([]int, len(idx.words))
+
+	buffer := []uint32{}
+	bp128.MakeAlignedSlice(idx.chunkSize(), &buffer)
+	for _, b := range idx.blocks {
+		for _, p := range b.posts {
+			bp128.Unpack(p.words, &buffer)
+			for _, w := range buffer {
+				freqs[idx.freqword[w]]++
+			}
+		}
+	}
+
+	return freqs
+}
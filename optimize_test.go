@@ -0,0 +1,67 @@
+package hyb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimizeLayout(t *testing.T) {
+	index, docs := createIndex("files/books.txt.gz")
+
+	sample := []string{}
+	for _, d := range docs[:200] {
+		for _, w := range strings.Fields(d) {
+			for i := 1; i <= len(w); i++ {
+				sample = append(sample, w[:i])
+			}
+		}
+	}
+
+	plan := OptimizeLayout(index, sample, 0)
+	assert.Equal(t, CustomBlocks, plan.Options.Strategy)
+	assert.Len(t, plan.Options.Boundaries, plan.Options.NumBlocks-1)
+	assert.True(t, plan.Predicted.Total() <= plan.Current.Total())
+
+	// The predicted scan cost is exact
+	assert.InDelta(t, plan.Current.Scanned, MeasureCost(index, sample).Scanned, 1e-6)
+
+	b := NewBuilder()
+	b.SetOptions(plan.Options)
+	for i, d := range docs {
+		b.Add(i, strings.Fields(d), len(docs)-i)
+	}
+	oindex := b.Build()
+	assert.Len(t, oindex.Stats().Blocks, plan.Options.NumBlocks)
+	assert.InDelta(t, plan.Predicted.Scanned, MeasureCost(oindex, sample).Scanned, 1e-6)
+
+	// Search results are the same
+	for _, d := range docs[:20] {
+		expected := &Result{}
+		res := &Result{}
+		for _, q := range substrings(d) {
+			query := strings.Fields(q)
+			index.Search(query, expected)
+			oindex.Search(query, res)
+
+			assert.Equal(t, hitIDs(expected.TopHits(10)), hitIDs(res.TopHits(10)), q)
+			assert.Equal(t, expected.Completions(), res.Completions(), q)
+		}
+	}
+
+	// A size limit trades speed for space
+	small := OptimizeLayout(index, sample, plan.Predicted.Size*9/10)
+	assert.True(t, small.Predicted.Size <= plan.Predicted.Size*9/10 || small.Options.NumBlocks == 1)
+	assert.True(t, small.Predicted.Total() >= plan.Predicted.Total())
+}
+
+func TestCreateCustomBlocks(t *testing.T) {
+	words := []string{"a", "b", "c", "d", "e"}
+	blocks := createCustomBlocks([]string{"d", "bb", "a", "z"}, words, []int{1, 2, 3, 4, 5})
+	assert.Equal(t, []block{
+		{boundary: [2]int{0, 1}, length: 3},
+		{boundary: [2]int{2, 2}, length: 3},
+		{boundary: [2]int{3, 4}, length: 9},
+	}, blocks)
+}
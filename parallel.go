@@ -0,0 +1,187 @@
+package hyb
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/robskie/bp128"
+)
+
+// minParallelSort is the minimum number of
+// elements before a slice is sorted in parallel.
+const minParallelSort = 1 << 14
+
+// SetWorkers sets the number of goroutines used by Build
+// to sort, to compute the character frequencies and to
+// compress the postings. If n is zero or negative, it
+// uses GOMAXPROCS goroutines, which is the default. The
+// index is the same regardless of the number of workers.
+func (b *Builder) SetWorkers(n int) {
+	b.workers = n
+}
+
+func (b *Builder) numWorkers() int {
+	if b.workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return b.workers
+}
+
+// parallelSort sorts s using the given number of workers.
+// The runs are sorted concurrently and then merged. Since
+// less must be a strict total order, the result is the
+// same as a serial sort.
+func parallelSort[T any](s []T, less func(a, b T) bool, workers int) {
+	n := len(s)
+	if workers <= 1 || n < minParallelSort {
+		sort.Slice(s, func(i, j int) bool { return less(s[i], s[j]) })
+		return
+	}
+
+	// Sort the runs
+	wg := sync.WaitGroup{}
+	size := (n + workers - 1) / workers
+	for lo := 0; lo < n; lo += size {
+		wg.Add(1)
+		go func(run []T) {
+			defer wg.Done()
+			sort.Slice(run, func(i, j int) bool { return less(run[i], run[j]) })
+		}(s[lo:min(lo+size, n)])
+	}
+	wg.Wait()
+
+	// Merge adjacent runs until
+	// there is only one run left
+	src, dst := s, make([]T, n)
+	for width := size; width < n; width *= 2 {
+		for lo := 0; lo < n; lo += 2 * width {
+			mid := min(lo+width, n)
+			hi := min(lo+2*width, n)
+
+			wg.Add(1)
+			go func(dst, a, b []T) {
+				defer wg.Done()
+				mergeRuns(dst, a, b, less)
+			}(dst[lo:hi], src[lo:mid], src[mid:hi])
+		}
+		wg.Wait()
+
+		src, dst = dst, src
+	}
+
+	if &src[0] != &s[0] {
+		copy(s, src)
+	}
+}
+
+// mergeRuns merges the sorted runs a and b into dst.
+func mergeRuns[T any](dst, a, b []T, less func(a, b T) bool) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if less(b[j], a[i]) {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i]
+			i++
+		}
+		k++
+	}
+
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+}
+
+// chunkBuf holds the postings of a chunk before packing.
+type chunkBuf struct {
+	ids   []uint32
+	words []uint32
+	ranks []uint32
+}
+
+func newChunkBuf(size int) *chunkBuf {
+	c := &chunkBuf{}
+	bp128.MakeAlignedSlice(size, &c.ids)
+	bp128.MakeAlignedSlice(size, &c.words)
+	bp128.MakeAlignedSlice(size, &c.ranks)
+
+	return c
+}
+
+type packJob struct {
+	buf *chunkBuf
+	n   int
+	dst *cposting
+}
+
+// packer compresses chunks of postings. If it has more
+// than one worker, chunks are compressed concurrently.
+type packer struct {
+	jobs chan packJob
+	free chan *chunkBuf
+	wg   sync.WaitGroup
+
+	chunkSize int
+}
+
+// newPacker creates a packer given the number
+// of workers, the number of chunk buffers held
+// by its callers and the size of the chunks.
+func newPacker(workers, nbufs, chunkSize int) *packer {
+	p := &packer{chunkSize: chunkSize}
+	if workers <= 1 {
+		return p
+	}
+
+	p.jobs = make(chan packJob, workers)
+	p.free = make(chan *chunkBuf, workers+nbufs)
+	for i := 0; i < workers; i++ {
+		p.free <- newChunkBuf(chunkSize)
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				packChunk(job)
+				p.free <- job.buf
+			}
+		}()
+	}
+
+	return p
+}
+
+// pack compresses the first n postings of buf into dst.
+// It returns a buffer that the caller can reuse.
+func (p *packer) pack(buf *chunkBuf, n int, dst *cposting) *chunkBuf {
+	job := packJob{buf, n, dst}
+	if p.jobs == nil {
+		packChunk(job)
+		return buf
+	}
+
+	p.jobs <- job
+	return <-p.free
+}
+
+// wait waits for all the chunks to be compressed.
+func (p *packer) wait() {
+	if p.jobs != nil {
+		close(p.jobs)
+		p.wg.Wait()
+	}
+}
+
+func packChunk(job packJob) {
+	buf, n := job.buf, job.n
+
+	p := job.dst
+	p.ids = bp128.DeltaPack(buf.ids[:n])
+	p.words = bp128.Pack(buf.words[:n])
+	p.ranks = bp128.Pack(buf.ranks[:n])
+	p.iboundary = buf.ids[n-1]
+}
@@ -0,0 +1,73 @@
+package hyb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelSort(t *testing.T) {
+	for _, n := range []int{0, 1, 100, minParallelSort, 3*minParallelSort + 7} {
+		for _, workers := range []int{1, 2, 3, 8} {
+			s := make([]int, n)
+			for i := range s {
+				s[i] = rand.Intn(n/2 + 1)
+			}
+
+			expected := make([]int, n)
+			copy(expected, s)
+			sort.Ints(expected)
+
+			parallelSort(s, func(a, b int) bool { return a < b }, workers)
+			assert.Equal(t, expected, s)
+		}
+	}
+}
+
+func TestBuildParallel(t *testing.T) {
+	file, err := os.Open("files/movies.txt.gz")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	fgzip, err := gzip.NewReader(file)
+	assert.Nil(t, err)
+	defer fgzip.Close()
+
+	lines := [][]string{}
+	scanner := bufio.NewScanner(fgzip)
+	for i := 0; i < 50000 && scanner.Scan(); i++ {
+		lines = append(lines, strings.Fields(strings.ToLower(scanner.Text())))
+	}
+
+	build := func(workers int) []byte {
+		b := NewBuilder()
+		b.SetWorkers(workers)
+		b.EnablePhrases(2, 3)
+		b.AddQuery("star wars", 10)
+
+		// Use duplicate ranks so
+		// that ties must be broken
+		for i, l := range lines {
+			words := make([]string, len(l))
+			copy(words, l)
+			b.Add(i, words, i%100)
+		}
+
+		buf := &bytes.Buffer{}
+		assert.Nil(t, b.Build().Write(buf))
+
+		return buf.Bytes()
+	}
+
+	serial := build(1)
+	for _, workers := range []int{2, 4, 16} {
+		assert.True(t, bytes.Equal(serial, build(workers)))
+	}
+}
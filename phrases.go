@@ -0,0 +1,197 @@
+package hyb
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// phraseTable maps word n-grams to the
+// IDs of the documents that contain them.
+type phraseTable struct {
+	// phrases contains the n-grams sorted
+	// in lexicographical order. The words of
+	// each n-gram are separated by a space.
+	phrases []string
+
+	// offsets[i] and offsets[i+1] are the
+	// start and end of the IDs of phrases[i].
+	offsets []uint32
+	ids     []uint32
+}
+
+// getPhrases returns the word n-grams of keywords
+// from bigrams up to n-grams. Keywords must be in
+// their original order.
+func getPhrases(keywords []string, n int) []string {
+	phrases := []string{}
+	for l := 2; l <= n; l++ {
+		for i := 0; i+l <= len(keywords); i++ {
+			phrases = append(phrases, strings.Join(keywords[i:i+l], " "))
+		}
+	}
+
+	return phrases
+}
+
+// phraseBuilder creates a phrase table from docs sorted
+// by ID. The docs are counted in one pass and their IDs
+// are filled in a second pass.
+type phraseBuilder struct {
+	minDocs int
+	counts  map[string]int
+
+	// index maps the kept phrases to their
+	// position in the table and pos contains
+	// the next write position of each phrase.
+	index map[string]int
+	pos   []uint32
+
+	table phraseTable
+}
+
+func newPhraseBuilder(minDocs int) *phraseBuilder {
+	return &phraseBuilder{
+		minDocs: minDocs,
+		counts:  map[string]int{},
+	}
+}
+
+// count counts the unique phrases of d
+// which must be in lexicographical order.
+func (b *phraseBuilder) count(d doc) {
+	prev := ""
+	for _, p := range d.phrases {
+		if p != prev {
+			b.counts[p]++
+		}
+		prev = p
+	}
+}
+
+// build creates the phrases and offsets of the
+// table. Phrases that are contained in fewer
+// than minDocs documents are discarded.
+func (b *phraseBuilder) build() {
+	phrases := []string{}
+	for p, c := range b.counts {
+		if c >= b.minDocs {
+			phrases = append(phrases, p)
+		}
+	}
+
+	if len(phrases) == 0 {
+		return
+	}
+	sort.Strings(phrases)
+
+	// Create the offsets
+	b.index = make(map[string]int, len(phrases))
+	offsets := make([]uint32, len(phrases)+1)
+	for i, p := range phrases {
+		b.index[p] = i
+		offsets[i+1] = offsets[i] + uint32(b.counts[p])
+	}
+	b.counts = nil
+
+	b.pos = make([]uint32, len(phrases))
+	copy(b.pos, offsets)
+
+	ids := make([]uint32, offsets[len(phrases)])
+	b.table = phraseTable{phrases, offsets, ids}
+}
+
+// fill adds the ID of d to its phrases. Since
+// docs are sorted, this results in sorted IDs.
+func (b *phraseBuilder) fill(d doc) {
+	prev := ""
+	for _, p := range d.phrases {
+		if i, ok := b.index[p]; ok && p != prev {
+			b.table.ids[b.pos[i]] = uint32(d.id)
+			b.pos[i]++
+		}
+		prev = p
+	}
+}
+
+// size returns the size of the table in bytes.
+func (t *phraseTable) size() int {
+	size := 4 * (len(t.offsets) + len(t.ids))
+	for _, p := range t.phrases {
+		size += len(p)
+	}
+
+	return size
+}
+
+// complete returns the phrase completions of the query
+// together with the number of hits in results. The phrases
+// must start with the longest suffix of the query that
+// matches any phrase and must either have more words than
+// it or complete its last word. If results is nil, every
+// document of a phrase is counted as a hit.
+func (t *phraseTable) complete(query []string, results []iposting) []completion {
+	if len(t.phrases) == 0 || len(query) == 0 {
+		return nil
+	}
+
+	for i := range query {
+		prefix := strings.Join(query[i:], " ")
+		wrange := getWordRange(prefix, t.phrases, 0)
+		if wrange == nil {
+			continue
+		}
+
+		nwords := len(query) - i
+		comps := []completion{}
+		for pid := wrange[0]; pid <= wrange[1]; pid++ {
+			p := t.phrases[pid]
+			if strings.Count(p, " ")+1 < nwords || p == prefix {
+				continue
+			}
+
+			ids := t.ids[t.offsets[pid]:t.offsets[pid+1]]
+			hits := len(ids)
+			if results != nil {
+				hits = countCommon(results, ids)
+			}
+
+			if hits > 0 {
+				c := completion{word: pid, hits: hits}
+				c.score = float64(hits)
+				comps = append(comps, c)
+			}
+		}
+
+		if len(comps) > 0 {
+			return comps
+		}
+	}
+
+	return nil
+}
+
+// countCommon returns the number of unique
+// IDs in results which are also in ids.
+func countCommon(results []iposting, ids []uint32) int {
+	count := 0
+	i, j := 0, 0
+	pid := uint32(math.MaxUint32)
+	for i < len(results) && j < len(ids) {
+		rid := results[i].id
+		if rid == pid {
+			i++
+		} else if rid < ids[j] {
+			i++
+		} else if rid > ids[j] {
+			j++
+		} else {
+			count++
+			pid = rid
+			i++
+			j++
+		}
+	}
+
+	return count
+}
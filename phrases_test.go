@@ -0,0 +1,117 @@
+package hyb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPhrases(t *testing.T) {
+	words := strings.Fields("star wars episode iv")
+	phrases := getPhrases(words, 3)
+	assert.Equal(t, []string{
+		"star wars",
+		"wars episode",
+		"episode iv",
+		"star wars episode",
+		"wars episode iv",
+	}, phrases)
+}
+
+func TestPhraseCompletions(t *testing.T) {
+	docs := []string{
+		"star wars",
+		"star trek",
+		"star wars episode iv",
+		"starship troopers",
+		"lone star",
+		"star wars",
+		"the lone ranger",
+	}
+
+	b := NewBuilder()
+	b.EnablePhrases(3, 1)
+	for i, d := range docs {
+		b.Add(i, strings.Fields(d), i)
+	}
+
+	// Overwrite and delete some documents
+	b.Add(5, strings.Fields("trek star"), 5)
+	b.Delete(6)
+	index := b.Build()
+
+	tests := []struct {
+		query   string
+		phrases []Completion
+	}{
+		{"star", []Completion{
+			{"star wars", 2},
+			{"star trek", 1},
+			{"star wars episode", 1},
+			{"starship troopers", 1},
+		}},
+		{"star w", []Completion{
+			{"star wars", 2},
+			{"star wars episode", 1},
+		}},
+		{"star wars", []Completion{
+			{"star wars episode", 1},
+		}},
+		{"wars e", []Completion{
+			{"wars episode", 1},
+			{"wars episode iv", 1},
+		}},
+		{"lone", []Completion{
+			{"lone star", 1},
+		}},
+		{"tr", []Completion{
+			{"trek star", 1},
+		}},
+		{"ranger", []Completion{}},
+	}
+
+	res := &Result{}
+	for _, tt := range tests {
+		index.Search(strings.Fields(tt.query), res)
+
+		phrases := []Completion{}
+		for it := res.TopPhrases(10); it.Next(); {
+			phrases = append(phrases, it.Completion())
+		}
+		assert.Equal(t, tt.phrases, phrases, tt.query)
+	}
+
+	// Top k
+	index.Search([]string{"star"}, res)
+	it := res.TopPhrases(1)
+	assert.Equal(t, 1, it.Len())
+	assert.True(t, it.Next())
+	assert.Equal(t, Completion{"star wars", 2}, it.Completion())
+
+	// Minimum document count
+	b = NewBuilder()
+	b.EnablePhrases(2, 2)
+	for i, d := range docs {
+		b.Add(i, strings.Fields(d), i)
+	}
+	index = b.Build()
+
+	res = &Result{}
+	index.Search([]string{"star"}, res)
+
+	phrases := []Completion{}
+	for it := res.TopPhrases(10); it.Next(); {
+		phrases = append(phrases, it.Completion())
+	}
+	assert.Equal(t, []Completion{{"star wars", 3}}, phrases)
+
+	// Serialization
+	buf := &bytes.Buffer{}
+	assert.Nil(t, index.Write(buf))
+
+	nindex := NewIndex()
+	assert.Nil(t, nindex.Read(buf))
+	assert.Equal(t, index.phrases, nindex.phrases)
+}
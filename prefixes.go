@@ -0,0 +1,223 @@
+package hyb
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// prefixTable contains the precomputed top
+// hits and completions of the short prefixes.
+type prefixTable struct {
+	prefixes []string
+
+	// The hits of the ith prefix are from
+	// hoffsets[i] to hoffsets[i+1] of ids
+	// and ranks sorted by ID.
+	hoffsets []uint32
+	ids      []uint32
+	ranks    []uint32
+
+	// The completions of the ith prefix are
+	// from coffsets[i] to coffsets[i+1] of
+	// the completion arrays.
+	coffsets []uint32
+	words    []uint32
+	hits     []uint32
+	maxRanks []uint32
+	sumRanks []uint64
+
+	// totals contains the number of
+	// documents matched by each prefix.
+	totals []uint32
+}
+
+// PrecomputePrefixes makes Build store the top k hits and
+// completions of every prefix of up to n characters of the
+// words of the documents. Search answers these prefixes
+// from the stored table without touching the postings.
+// Since short prefixes match most of the documents, they
+// are the most expensive queries. See Precomputed for
+// the limitations of a precomputed result.
+func (b *Builder) PrecomputePrefixes(n, k int) {
+	b.topLen = n
+	b.topK = k
+}
+
+// buildPrefixTable searches every prefix of up
+// to n characters of the words of the index.
+func buildPrefixTable(idx *Index, n, k, workers int) prefixTable {
+	pset := map[string]bool{}
+	for _, w := range idx.words {
+		for j, end := 0, 0; j < n && end < len(w); j++ {
+			_, size := utf8.DecodeRuneInString(w[end:])
+			end += size
+			pset[w[:end]] = true
+		}
+	}
+
+	prefixes := make([]string, 0, len(pset))
+	for p := range pset {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	hits := make([][]iposting, len(prefixes))
+	comps := make([][]completion, len(prefixes))
+	totals := make([]uint32, len(prefixes))
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			res := &Result{words: idx.words, wordpop: idx.wordpop}
+			for i := w; i < len(prefixes); i += workers {
+				idx.search(context.Background(), prefixes[i], res)
+
+				h := res.topHits(k, nil)
+				sort.Slice(h, func(a, b int) bool { return h[a].id < h[b].id })
+				hits[i] = h
+				comps[i] = res.topCompletions(k, nil)
+				totals[i] = uint32(res.Total())
+
+				res.clear()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	t := prefixTable{
+		prefixes: prefixes,
+		hoffsets: make([]uint32, 1, len(prefixes)+1),
+		coffsets: make([]uint32, 1, len(prefixes)+1),
+		totals:   totals,
+	}
+	for i := range prefixes {
+		for _, p := range hits[i] {
+			t.ids = append(t.ids, p.id)
+			t.ranks = append(t.ranks, p.rank)
+		}
+		t.hoffsets = append(t.hoffsets, uint32(len(t.ids)))
+
+		for _, c := range comps[i] {
+			t.words = append(t.words, c.word)
+			t.hits = append(t.hits, uint32(c.hits))
+			t.maxRanks = append(t.maxRanks, c.maxRank)
+			t.sumRanks = append(t.sumRanks, c.sumRank)
+		}
+		t.coffsets = append(t.coffsets, uint32(len(t.words)))
+	}
+
+	return t
+}
+
+// find returns the index of the query
+// or -1 if it is not in the table.
+func (t *prefixTable) find(query string) int {
+	i := sort.SearchStrings(t.prefixes, query)
+	if i == len(t.prefixes) || t.prefixes[i] != query {
+		return -1
+	}
+
+	return i
+}
+
+// fill sets the results and the completions
+// of r to those of the ith prefix.
+func (t *prefixTable) fill(i int, r *Result) {
+	wrange := getWordRange(t.prefixes[i], r.words, 0)
+
+	r.results = r.results[:0]
+	for j := t.hoffsets[i]; j < t.hoffsets[i+1]; j++ {
+		r.results = append(r.results, iposting{t.ids[j], wrange[0], t.ranks[j]})
+	}
+
+	r.compbuf = r.compbuf[:0]
+	for j := t.coffsets[i]; j < t.coffsets[i+1]; j++ {
+		r.compbuf = append(r.compbuf, completion{
+			word:    t.words[j],
+			hits:    int(t.hits[j]),
+			maxRank: t.maxRanks[j],
+			sumRank: t.sumRanks[j],
+		})
+	}
+
+	r.completions = r.compbuf
+	r.wrange = wrange
+
+	// Tables without totals only
+	// know the stored hits
+	r.total = len(r.results)
+	if len(t.totals) > 0 {
+		r.total = int(t.totals[i])
+	}
+}
+
+// size returns the size of the table in bytes.
+func (t *prefixTable) size() int {
+	size := 4 * (len(t.hoffsets) + len(t.ids) + len(t.ranks))
+	size += 4 * (len(t.coffsets) + len(t.words) + len(t.hits) + len(t.maxRanks))
+	size += 4 * len(t.totals)
+	size += 8 * len(t.sumRanks)
+	for _, p := range t.prefixes {
+		size += len(p)
+	}
+
+	return size
+}
+
+// GobEncode transforms a prefix table into gob streams.
+func (t *prefixTable) GobEncode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+
+	err := checkErr(
+		enc.Encode(t.prefixes),
+		enc.Encode(t.hoffsets),
+		enc.Encode(t.ids),
+		enc.Encode(t.ranks),
+		enc.Encode(t.coffsets),
+		enc.Encode(t.words),
+		enc.Encode(t.hits),
+		enc.Encode(t.maxRanks),
+		enc.Encode(t.sumRanks),
+		enc.Encode(t.totals),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("hyb: encode failed (%v)", err)
+	}
+
+	return buf.Bytes(), err
+}
+
+// GobDecode decodes a prefix table from gob streams.
+func (t *prefixTable) GobDecode(data []byte) error {
+	buf := bytes.NewReader(data)
+	dec := gob.NewDecoder(buf)
+
+	err := checkErr(
+		dec.Decode(&t.prefixes),
+		dec.Decode(&t.hoffsets),
+		dec.Decode(&t.ids),
+		dec.Decode(&t.ranks),
+		dec.Decode(&t.coffsets),
+		dec.Decode(&t.words),
+		dec.Decode(&t.hits),
+		dec.Decode(&t.maxRanks),
+		dec.Decode(&t.sumRanks),
+		dec.Decode(&t.totals),
+	)
+
+	if err != nil {
+		err = fmt.Errorf("hyb: decode failed (%v)", err)
+	}
+
+	return err
+}
@@ -0,0 +1,75 @@
+package hyb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecomputePrefixes(t *testing.T) {
+	_, docs := createIndex("files/books.txt.gz")
+
+	build := func(n, k int) *Index {
+		b := NewBuilder()
+		b.EnablePhrases(3, 2)
+		b.PrecomputePrefixes(n, k)
+		for i, d := range docs {
+			b.Add(i, strings.Fields(d), len(docs)-i)
+		}
+		return b.Build()
+	}
+	index := build(0, 0)
+	pindex := build(2, 10)
+	assert.True(t, pindex.Size() > index.Size())
+
+	// The table survives serialization
+	buf := &bytes.Buffer{}
+	assert.Nil(t, pindex.Write(buf))
+	rindex := NewIndex()
+	assert.Nil(t, rindex.Read(buf))
+
+	queries := [][2]string{
+		{"t", "the"},
+		{"th", "the"},
+		{"s", "sta"},
+		{"st", "sta"},
+		{"a", "and"},
+	}
+	for _, qs := range queries {
+		q := qs[0]
+
+		expected := &Result{}
+		index.Search([]string{q}, expected)
+
+		res := &Result{}
+		rindex.Search([]string{q}, res)
+		assert.Equal(t, Precomputed, res.Path(), q)
+		assert.Equal(t, 0, res.scanned)
+
+		assert.Equal(t, hitIDs(expected.TopHits(10)), hitIDs(res.TopHits(10)), q)
+		assert.Equal(t, expected.Hits().Len(), res.Total(), q)
+		assert.Equal(t, expected.Hits().Len(), expected.Total(), q)
+		assert.Equal(t, expected.TopCompletions(10), res.TopCompletions(10), q)
+		assert.Equal(t, expected.TopPhrases(10), res.TopPhrases(10), q)
+
+		// A precomputed result cannot be continued
+		rindex.Search([]string{qs[1]}, res)
+		index.Search([]string{qs[1]}, expected)
+		assert.Equal(t, FullSearch, res.Path(), q)
+		assert.Equal(t, hitIDs(expected.Hits()), hitIDs(res.Hits()), q)
+		assert.Equal(t, expected.Completions(), res.Completions(), q)
+	}
+
+	// Multi-word queries and custom scorers
+	// are not answered from the table
+	res := &Result{}
+	pindex.Search([]string{"the", "s"}, res)
+	assert.Equal(t, FullSearch, res.Path())
+
+	res = &Result{}
+	res.SetCompletionScorer(ByMaxRank)
+	pindex.Search([]string{"s"}, res)
+	assert.Equal(t, FullSearch, res.Path())
+}
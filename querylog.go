@@ -0,0 +1,212 @@
+package hyb
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// AddQuery adds a query from a query log given the number
+// of times it was searched. A query matches a document if
+// all its words are prefixes of the document keywords, the
+// same as Index.Search. Documents matched by popular queries
+// get a higher rank, and the keywords picked by those queries
+// get a higher completion score.
+func (b *Builder) AddQuery(query string, count int) {
+	if count > 0 && len(strings.Fields(query)) > 0 {
+		b.queries[query] += count
+	}
+}
+
+// SetQueryWeight sets how much the query log affects the
+// rank of the documents. A weight of 0 ignores the query
+// log and a weight of 1 ranks the documents only by their
+// popularity. The default is 0.5.
+func (b *Builder) SetQueryWeight(w float64) {
+	b.queryWeight = math.Max(0, math.Min(1, w))
+}
+
+// queryMatcher finds the logged queries that match
+// each document and the keywords they pick.
+type queryMatcher struct {
+	queries []string
+	counts  []int
+
+	// qwords contains the unique words of each
+	// query and words maps each of those words
+	// to the queries that contain it.
+	qwords [][]string
+	words  map[string][]int
+	maxLen int
+
+	picked map[pickedWord]bool
+}
+
+// pickedWord is a keyword picked by a query.
+type pickedWord struct {
+	query int
+	word  string
+}
+
+func newQueryMatcher(queries map[string]int) *queryMatcher {
+	m := &queryMatcher{
+		words:  map[string][]int{},
+		picked: map[pickedWord]bool{},
+	}
+
+	for query, count := range queries {
+		qwords := strings.Fields(query)
+		sort.Strings(qwords)
+
+		// Remove duplicate words
+		uwords := qwords[:0]
+		for i, w := range qwords {
+			if i == 0 || w != qwords[i-1] {
+				uwords = append(uwords, w)
+			}
+		}
+
+		qi := len(m.counts)
+		for _, w := range uwords {
+			m.words[w] = append(m.words[w], qi)
+			m.maxLen = max(m.maxLen, len(w))
+		}
+		m.queries = append(m.queries, query)
+		m.counts = append(m.counts, count)
+		m.qwords = append(m.qwords, uwords)
+	}
+
+	return m
+}
+
+// match returns the popularity of d, which is the total
+// count of the queries that match it, and records the
+// keywords of d picked by those queries. A query matches
+// d if all its words are prefixes of the keywords of d.
+func (m *queryMatcher) match(d doc) int {
+	// Count the query words that are
+	// prefixes of any of the keywords
+	seen := map[string]bool{}
+	nwords := map[int]int{}
+	for _, w := range d.words {
+		for l := 1; l <= len(w) && l <= m.maxLen; l++ {
+			prefix := w[:l]
+			if qs, ok := m.words[prefix]; ok && !seen[prefix] {
+				seen[prefix] = true
+				for _, q := range qs {
+					nwords[q]++
+				}
+			}
+		}
+	}
+
+	pop := 0
+	for q, n := range nwords {
+		if n < len(m.qwords[q]) {
+			continue
+		}
+		pop += m.counts[q]
+
+		for _, w := range d.words {
+			for _, qw := range m.qwords[q] {
+				if strings.HasPrefix(w, qw) {
+					m.picked[pickedWord{q, w}] = true
+					break
+				}
+			}
+		}
+	}
+
+	return pop
+}
+
+// wordPopularity returns the total count of the
+// queries that picked each keyword. A query counts
+// once per keyword no matter how many documents
+// it matches.
+func (m *queryMatcher) wordPopularity() map[string]int {
+	wordpop := map[string]int{}
+	for p := range m.picked {
+		wordpop[p.word] += m.counts[p.query]
+	}
+
+	return wordpop
+}
+
+// mergeWordPopularity returns the word popularity of
+// the matchers of several shards as if they were a
+// single matcher. A query that picks a keyword in
+// more than one shard is only counted once.
+func mergeWordPopularity(matchers []*queryMatcher) map[string]int {
+	type pick struct {
+		query string
+		word  string
+	}
+
+	picked := map[pick]int{}
+	for _, m := range matchers {
+		if m == nil {
+			continue
+		}
+
+		for p := range m.picked {
+			picked[pick{m.queries[p.query], p.word}] = m.counts[p.query]
+		}
+	}
+
+	wordpop := map[string]int{}
+	for p, count := range picked {
+		wordpop[p.word] += count
+	}
+
+	return wordpop
+}
+
+// blendRanks replaces the normalized ranks with ranks
+// that blend the original rank and the popularity of
+// each doc given the weight of the popularity. Ties
+// are broken by the original rank and then by the
+// given policy.
+func blendRanks(ranks, docpop []int, weight float64, counts []int, ties TieBreak, workers int) {
+	maxRank, maxPop := 0, 0
+	for i := range ranks {
+		maxRank = max(maxRank, ranks[i])
+		maxPop = max(maxPop, docpop[i])
+	}
+
+	// Use the logarithm of the popularity so
+	// that a few very popular documents don't
+	// flatten the rest.
+	scores := make([]float64, len(ranks))
+	for i := range ranks {
+		var r, p float64
+		if maxRank > 0 {
+			r = float64(ranks[i]) / float64(maxRank)
+		}
+		if maxPop > 0 {
+			p = math.Log1p(float64(docpop[i])) / math.Log1p(float64(maxPop))
+		}
+
+		scores[i] = (1-weight)*r + weight*p
+	}
+
+	order := make([]int, len(ranks))
+	for i := range order {
+		order[i] = i
+	}
+	before := ties.before(counts)
+	parallelSort(order, func(a, b int) bool {
+		if scores[a] != scores[b] {
+			return scores[a] < scores[b]
+		}
+		if ranks[a] != ranks[b] {
+			return ranks[a] < ranks[b]
+		}
+
+		return before(a, b)
+	}, workers)
+
+	ties.setPositions(ranks, order, func(a, b int) bool {
+		return scores[a] == scores[b] && ranks[a] == ranks[b]
+	})
+}
@@ -0,0 +1,92 @@
+package hyb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLog(t *testing.T) {
+	docs := []string{
+		"alien",
+		"aliens",
+		"alien resurrection",
+		"all about eve",
+		"amadeus",
+	}
+
+	build := func(queries map[string]int, weight float64) *Index {
+		b := NewBuilder()
+		for i, d := range docs {
+			// Earlier documents have higher ranks
+			b.Add(i, strings.Fields(d), len(docs)-i)
+		}
+		for q, c := range queries {
+			b.AddQuery(q, c)
+		}
+		b.SetQueryWeight(weight)
+
+		return b.Build()
+	}
+
+	search := func(index *Index, query string) ([]int, []Completion) {
+		res := &Result{}
+		index.Search(strings.Fields(query), res)
+
+		ids := []int{}
+		for it := res.Hits(); it.Next(); {
+			ids = append(ids, it.ID())
+		}
+
+		comps := []Completion{}
+		for it := res.Completions(); it.Next(); {
+			comps = append(comps, it.Completion())
+		}
+
+		return ids, comps
+	}
+
+	// Without a query log
+	index := build(nil, 0.5)
+	assert.Nil(t, index.wordpop)
+
+	ids, comps := search(index, "al")
+	assert.Equal(t, []int{0, 1, 2, 3}, ids)
+	assert.Equal(t, []Completion{
+		{"alien", 2},
+		{"aliens", 1},
+		{"all", 1},
+	}, comps)
+
+	// With a query log
+	queries := map[string]int{
+		"all abo": 100,
+		"aliens":  10,
+		"zzz":     1000,
+	}
+	index = build(queries, 0.5)
+	assert.NotNil(t, index.wordpop)
+
+	ids, comps = search(index, "al")
+	assert.Equal(t, []int{1, 3, 0, 2}, ids)
+	assert.Equal(t, []Completion{
+		{"all", 1},
+		{"aliens", 1},
+		{"alien", 2},
+	}, comps)
+
+	// Zero weight keeps the original ranks
+	index = build(queries, 0)
+	ids, _ = search(index, "al")
+	assert.Equal(t, []int{0, 1, 2, 3}, ids)
+
+	// Serialization
+	buf := &bytes.Buffer{}
+	assert.Nil(t, index.Write(buf))
+
+	nindex := NewIndex()
+	assert.Nil(t, nindex.Read(buf))
+	assert.Equal(t, index.wordpop, nindex.wordpop)
+}
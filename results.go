@@ -11,7 +11,7 @@ import (
 type postHeap []iposting
 
 func (h postHeap) Len() int           { return len(h) }
-func (h postHeap) Less(i, j int) bool { return h[i].rank < h[j].rank }
+func (h postHeap) Less(i, j int) bool { return hitBefore(h[j], h[i]) }
 func (h postHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
 func (h postHeap) Peek() iposting     { return h[0] }
 
@@ -32,7 +32,7 @@ func (h *postHeap) Pop() interface{} {
 type compHeap []completion
 
 func (h compHeap) Len() int           { return len(h) }
-func (h compHeap) Less(i, j int) bool { return h[i].hits < h[j].hits }
+func (h compHeap) Less(i, j int) bool { return compBefore(h[j], h[i]) }
 func (h compHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
 func (h compHeap) Peek() completion   { return h[0] }
 
@@ -51,30 +51,67 @@ func (h *compHeap) Pop() interface{} {
 type ranks []iposting
 
 func (p ranks) Len() int           { return len(p) }
-func (p ranks) Less(i, j int) bool { return p[i].rank > p[j].rank }
+func (p ranks) Less(i, j int) bool { return hitBefore(p[i], p[j]) }
 func (p ranks) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
 type completion struct {
 	word uint32
 	hits int
+
+	// maxRank and sumRank are the maximum
+	// and the sum of the ranks of the hits.
+	maxRank uint32
+	sumRank uint64
+
+	score float64
+}
+
+// add counts a hit with the given rank.
+func (c *completion) add(rank uint32) {
+	c.hits++
+	c.sumRank += uint64(rank)
+	if rank > c.maxRank {
+		c.maxRank = rank
+	}
 }
 
-type byHits []completion
+type byScore []completion
 
-func (c byHits) Len() int { return len(c) }
-func (c byHits) Less(i, j int) bool {
-	if c[i].hits != c[j].hits {
-		return c[i].hits > c[j].hits
+func (c byScore) Len() int           { return len(c) }
+func (c byScore) Less(i, j int) bool { return compBefore(c[i], c[j]) }
+func (c byScore) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+// hitBefore returns true if a comes before b in the
+// hit order. Hits are sorted by decreasing rank and
+// ties are broken by increasing ID.
+func hitBefore(a, b iposting) bool {
+	if a.rank != b.rank {
+		return a.rank > b.rank
 	}
 
-	return c[i].word < c[j].word
+	return a.id < b.id
+}
+
+// compBefore returns true if a comes before b in the
+// completion order. Completions are sorted by decreasing
+// score and ties are broken by increasing word id which
+// is also the lexicographical order.
+func compBefore(a, b completion) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+
+	return a.word < b.word
 }
-func (c byHits) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
 // Hits iterates over the result of a search.
 type Hits struct {
 	results []iposting
 	current int
+
+	// start is the cursor of the hit
+	// that precedes the first result.
+	start string
 }
 
 // Next increments the iterator to the next result.
@@ -93,6 +130,23 @@ func (h *Hits) ID() int {
 	return int(h.results[h.current].id)
 }
 
+// Len returns the number of results in the iterator.
+func (h *Hits) Len() int {
+	return len(h.results)
+}
+
+// Cursor returns an opaque token that marks the position
+// of the current result. Pass it to Result.HitsAfter to
+// resume the iteration after this result.
+func (h *Hits) Cursor() string {
+	if h.current < 0 || len(h.results) == 0 {
+		return h.start
+	}
+
+	i := min(h.current, len(h.results)-1)
+	return encodeHitCursor(h.results[i])
+}
+
 // Completion represents the
 // completions of the last query word.
 type Completion struct {
@@ -100,6 +154,51 @@ type Completion struct {
 	Hits int
 }
 
+// CompletionStats contains the aggregates of the
+// hits of a completion. Ranks are normalized such
+// that the best document has the highest rank.
+type CompletionStats struct {
+	Word    string
+	Hits    int
+	MaxRank int
+	SumRank int
+
+	// Popularity is the number of logged
+	// queries that picked the word. It is
+	// zero if the index has no query log.
+	Popularity int
+}
+
+// CompletionScorer computes the score of a completion.
+// Completions are sorted by decreasing score.
+type CompletionScorer func(s CompletionStats) float64
+
+// ByHits scores a completion by its number of hits.
+// This is the default scorer.
+func ByHits(s CompletionStats) float64 {
+	return float64(s.Hits)
+}
+
+// ByMaxRank scores a completion by the
+// rank of its best matching document.
+func ByMaxRank(s CompletionStats) float64 {
+	return float64(s.MaxRank)
+}
+
+// BySumRank scores a completion by the sum
+// of the ranks of its matching documents.
+func BySumRank(s CompletionStats) float64 {
+	return float64(s.SumRank)
+}
+
+// ByPopularity scores a completion by its number of
+// hits boosted logarithmically by its query log
+// popularity. This is the default scorer if the
+// index has a query log.
+func ByPopularity(s CompletionStats) float64 {
+	return float64(s.Hits) * (1 + math.Log1p(float64(s.Popularity)))
+}
+
 type hits []Completion
 
 func (c hits) Len() int { return len(c) }
@@ -119,6 +218,10 @@ type Completions struct {
 	current int
 
 	words []string
+
+	// start is the cursor of the completion
+	// that precedes the first result.
+	start string
 }
 
 // Next increments the iterator to the next completion.
@@ -143,8 +246,26 @@ func (c *Completions) Completion() Completion {
 	return Completion{c.words[res.word], res.hits}
 }
 
+// Len returns the number of completions in the iterator.
+func (c *Completions) Len() int {
+	return len(c.results)
+}
+
+// Cursor returns an opaque token that marks the position
+// of the current completion. Pass it to Result.CompletionsAfter
+// to resume the iteration after this completion.
+func (c *Completions) Cursor() string {
+	if c.current < 0 || len(c.results) == 0 {
+		return c.start
+	}
+
+	res := c.results[min(c.current, len(c.results)-1)]
+	return encodeCompCursor(res.score, c.words[res.word])
+}
+
 // Result contains the search result.
 type Result struct {
+	index   *Index
 	query   []string
 	results []iposting
 
@@ -153,12 +274,130 @@ type Result struct {
 
 	compbuf     []completion
 	completions []completion
+
+	phrases *phraseTable
+	wordpop []uint32
+
+	scorer CompletionScorer
+
+	history historyStack
+	path    SearchPath
+
+	// partial is true if the results only
+	// contain the top precomputed hits, and
+	// total is then the number of matches.
+	partial bool
+	total   int
+
+	tracing bool
+	trace   *SearchTrace
+
+	// conds are the sorted query words that
+	// the results match. It is the key of the
+	// results in the search cache.
+	conds []string
+
+	// scanned is the number of postings unpacked
+	// by the last search, and cacheHits and
+	// cacheMisses count its query words that
+	// were and were not in the search cache.
+	scanned     int
+	cacheHits   int
+	cacheMisses int
+}
+
+// SearchPath tells how the last search
+// of a result was performed.
+type SearchPath int
+
+// Search paths returned by Result.Path.
+const (
+	// FullSearch means that the query
+	// was searched in the index blocks.
+	FullSearch SearchPath = iota
+
+	// Continuation means that the query extends the
+	// previous query so only the previous result was
+	// filtered or intersected.
+	Continuation
+
+	// Restored means that the result was
+	// restored from the history of the result.
+	Restored
+
+	// Precomputed means that the query was answered from
+	// the precomputed results of the index. Such a result
+	// only contains the top hits and completions that were
+	// stored, so Hits, Completions and their pages have at
+	// most that many entries, but Total counts every match.
+	// See Builder.PrecomputePrefixes.
+	Precomputed
+
+	// NumSearchPaths is the number of search paths.
+	NumSearchPaths
+)
+
+func (p SearchPath) String() string {
+	switch p {
+	case FullSearch:
+		return "full"
+	case Continuation:
+		return "continuation"
+	case Restored:
+		return "restored"
+	case Precomputed:
+		return "precomputed"
+	}
+
+	return "unknown"
+}
+
+// Path returns how the last search was performed.
+func (r *Result) Path() SearchPath {
+	return r.path
+}
+
+// SetCompletionScorer sets the function used to order
+// completions. If s is nil, completions are ordered by
+// their number of hits, or by ByPopularity if the index
+// has a query log.
+func (r *Result) SetCompletionScorer(s CompletionScorer) {
+	r.scorer = s
+}
+
+// scored returns a copy of c with its score set.
+func (r *Result) scored(c completion) completion {
+	if r.scorer == nil && r.wordpop == nil {
+		c.score = float64(c.hits)
+		return c
+	}
+
+	s := CompletionStats{
+		Word:    r.words[c.word],
+		Hits:    c.hits,
+		MaxRank: int(c.maxRank),
+		SumRank: int(c.sumRank),
+	}
+	if r.wordpop != nil {
+		s.Popularity = int(r.wordpop[c.word])
+	}
+
+	scorer := r.scorer
+	if scorer == nil {
+		scorer = ByPopularity
+	}
+	c.score = scorer(s)
+
+	return c
 }
 
 func (r *Result) clear() {
 	r.results = nil
 	r.wrange = nil
 	r.completions = nil
+	r.conds = nil
+	r.partial = false
+	r.total = 0
 }
 
 // Hits returns all the IDs that match a
@@ -177,15 +416,88 @@ func (r *Result) Hits() *Hits {
 
 	sort.Sort(ranks(cpy))
 
-	return &Hits{cpy, -1}
+	return &Hits{cpy, -1, ""}
+}
+
+// Total returns the number of documents that match the
+// query. Unlike Hits, it counts every match of a
+// Precomputed result, and it does not sort the hits.
+func (r *Result) Total() int {
+	if r.partial {
+		return r.total
+	}
+
+	n := 0
+	pid := uint32(math.MaxUint32)
+	for _, p := range r.results {
+		if p.id != pid {
+			n++
+			pid = p.id
+		}
+	}
+
+	return n
 }
 
 // TopHits returns the top k document IDs
 // that match the given query sorted by
 // decreasing rank.
 func (r *Result) TopHits(k int) *Hits {
-	if k == 0 {
-		return &Hits{nil, -1}
+	return &Hits{r.topHits(k, nil), -1, ""}
+}
+
+// HitsPage returns at most limit document IDs that
+// match the given query starting from the given offset.
+// The IDs are sorted by decreasing rank and ties are
+// broken by increasing ID, so consecutive pages never
+// overlap. HitsPage keeps the top offset+limit hits to
+// return a single page, so deep pages cost more. Use
+// HitsAfter with the cursor of the previous page to
+// iterate through all the hits.
+func (r *Result) HitsPage(offset, limit int) *Hits {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit <= 0 {
+		return &Hits{nil, -1, ""}
+	}
+
+	top := r.topHits(offset+limit, nil)
+	if offset >= len(top) {
+		return &Hits{nil, -1, ""}
+	}
+
+	start := ""
+	if offset > 0 {
+		start = encodeHitCursor(top[offset-1])
+	}
+
+	return &Hits{top[offset:], -1, start}
+}
+
+// HitsAfter returns at most limit document IDs that come
+// after the hit marked by cursor. An empty cursor starts
+// from the best hit. The order is the same as HitsPage.
+func (r *Result) HitsAfter(cursor string, limit int) (*Hits, error) {
+	var after *iposting
+	if cursor != "" {
+		p, err := decodeHitCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &p
+	}
+
+	return &Hits{r.topHits(limit, after), -1, cursor}, nil
+}
+
+// topHits returns the top k unique postings that come
+// after the given posting sorted in hit order. If after
+// is nil, it starts from the best posting.
+func (r *Result) topHits(k int, after *iposting) []iposting {
+	if k <= 0 {
+		return nil
 	}
 
 	h := &postHeap{}
@@ -199,9 +511,13 @@ func (r *Result) TopHits(k int) *Hits {
 		}
 		pid = p.id
 
+		if after != nil && !hitBefore(*after, p) {
+			continue
+		}
+
 		if h.Len() < k {
 			heap.Push(h, p)
-		} else if p.rank > h.Peek().rank {
+		} else if hitBefore(p, h.Peek()) {
 			heap.Pop(h)
 			heap.Push(h, p)
 		}
@@ -209,39 +525,139 @@ func (r *Result) TopHits(k int) *Hits {
 
 	sort.Sort(ranks(*h))
 
-	return &Hits{*h, -1}
+	return *h
 }
 
 // Completions returns all word completions of
-// the last query word sorted by decreasing number
-// of hits.
+// the last query word sorted by decreasing score.
 func (r *Result) Completions() *Completions {
-	cpy := make([]completion, len(r.completions))
-	copy(cpy, r.completions)
-	sort.Sort(byHits(cpy))
+	cpy := make([]completion, 0, len(r.completions))
+	for _, c := range r.completions {
+		if c.hits > 0 {
+			cpy = append(cpy, r.scored(c))
+		}
+	}
+	sort.Sort(byScore(cpy))
 
-	return &Completions{cpy, -1, r.words}
+	return &Completions{cpy, -1, r.words, ""}
 }
 
 // TopCompletions returns the top k completions of the
-// last query word sorted by decreasing number of hits.
+// last query word sorted by decreasing score.
 func (r *Result) TopCompletions(k int) *Completions {
-	if k == 0 {
-		return &Completions{nil, -1, nil}
+	return &Completions{r.topCompletions(k, nil), -1, r.words, ""}
+}
+
+// CompletionsPage returns at most limit completions of the
+// last query word starting from the given offset. The
+// completions are sorted by decreasing score and ties
+// are broken by the lexicographical order of the words.
+// Like HitsPage, it keeps the top offset+limit completions,
+// so use CompletionsAfter to iterate through deep pages.
+func (r *Result) CompletionsPage(offset, limit int) *Completions {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit <= 0 {
+		return &Completions{nil, -1, r.words, ""}
+	}
+
+	top := r.topCompletions(offset+limit, nil)
+	if offset >= len(top) {
+		return &Completions{nil, -1, r.words, ""}
+	}
+
+	start := ""
+	if offset > 0 {
+		c := top[offset-1]
+		start = encodeCompCursor(c.score, r.words[c.word])
+	}
+
+	return &Completions{top[offset:], -1, r.words, start}
+}
+
+// CompletionsAfter returns at most limit completions that
+// come after the completion marked by cursor. An empty cursor
+// starts from the best completion. The order is the same as
+// CompletionsPage.
+func (r *Result) CompletionsAfter(cursor string, limit int) (*Completions, error) {
+	var after *compCursor
+	if cursor != "" {
+		c, err := decodeCompCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &c
+	}
+
+	return &Completions{r.topCompletions(limit, after), -1, r.words, cursor}, nil
+}
+
+// topCompletions returns the top k completions with at
+// least one hit that come after the given cursor sorted
+// in completion order. If after is nil, it starts from
+// the best completion.
+func (r *Result) topCompletions(k int, after *compCursor) []completion {
+	if k <= 0 {
+		return nil
 	}
 
 	h := &compHeap{}
 	heap.Init(h)
 	for _, c := range r.completions {
+		if c.hits == 0 {
+			continue
+		}
+
+		c = r.scored(c)
+		if after != nil && !after.before(c.score, r.words[c.word]) {
+			continue
+		}
+
+		if h.Len() < k {
+			heap.Push(h, c)
+		} else if compBefore(c, h.Peek()) {
+			heap.Pop(h)
+			heap.Push(h, c)
+		}
+	}
+
+	sort.Sort(byScore(*h))
+
+	return *h
+}
+
+// TopPhrases returns the top k phrase completions of the
+// query sorted by decreasing number of hits. A phrase
+// completion completes the last query word or extends the
+// query by one or more words. It returns an empty iterator
+// if the index was built without phrases.
+func (r *Result) TopPhrases(k int) *Completions {
+	if k <= 0 || r.phrases == nil || len(r.results) == 0 {
+		return &Completions{nil, -1, nil, ""}
+	}
+
+	h := &compHeap{}
+	heap.Init(h)
+	// A precomputed result does not have every hit, but
+	// every document of a phrase that completes its only
+	// word is a hit
+	results := r.results
+	if r.partial {
+		results = nil
+	}
+
+	for _, c := range r.phrases.complete(r.query, results) {
 		if h.Len() < k {
 			heap.Push(h, c)
-		} else if c.hits > h.Peek().hits {
+		} else if compBefore(c, h.Peek()) {
 			heap.Pop(h)
 			heap.Push(h, c)
 		}
 	}
 
-	sort.Sort(byHits(*h))
+	sort.Sort(byScore(*h))
 
-	return &Completions{*h, -1, r.words}
+	return &Completions{*h, -1, r.phrases.phrases, ""}
 }
@@ -0,0 +1,209 @@
+package hyb
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultPage(t *testing.T) {
+	index, _ := createIndex("files/books.txt.gz")
+
+	res := &Result{}
+	index.Search(strings.Fields("th"), res)
+
+	all := []int{}
+	for it := res.Hits(); it.Next(); {
+		all = append(all, it.ID())
+	}
+
+	allComps := []Completion{}
+	for it := res.Completions(); it.Next(); {
+		allComps = append(allComps, it.Completion())
+	}
+
+	const limit = 7
+	pageHits := []int{}
+	for offset := 0; ; offset += limit {
+		it := res.HitsPage(offset, limit)
+		if it.Len() == 0 {
+			break
+		}
+		for it.Next() {
+			pageHits = append(pageHits, it.ID())
+		}
+	}
+	assert.Equal(t, all, pageHits)
+
+	pageComps := []Completion{}
+	for offset := 0; ; offset += limit {
+		it := res.CompletionsPage(offset, limit)
+		if it.Len() == 0 {
+			break
+		}
+		for it.Next() {
+			pageComps = append(pageComps, it.Completion())
+		}
+	}
+	assert.Equal(t, allComps, pageComps)
+}
+
+func TestResultCursor(t *testing.T) {
+	index, _ := createIndex("files/books.txt.gz")
+
+	res := &Result{}
+	index.Search(strings.Fields("th"), res)
+
+	all := []int{}
+	for it := res.Hits(); it.Next(); {
+		all = append(all, it.ID())
+	}
+
+	allComps := []Completion{}
+	for it := res.Completions(); it.Next(); {
+		allComps = append(allComps, it.Completion())
+	}
+
+	const limit = 5
+	cursor := ""
+	cursorHits := []int{}
+	for {
+		// Use a fresh result to simulate
+		// resuming on another request.
+		nres := &Result{}
+		index.Search(strings.Fields("th"), nres)
+
+		it, err := nres.HitsAfter(cursor, limit)
+		if !assert.Nil(t, err) || it.Len() == 0 {
+			break
+		}
+		for it.Next() {
+			cursorHits = append(cursorHits, it.ID())
+		}
+		cursor = it.Cursor()
+	}
+	assert.Equal(t, all, cursorHits)
+
+	cursor = ""
+	cursorComps := []Completion{}
+	for {
+		it, err := res.CompletionsAfter(cursor, limit)
+		if !assert.Nil(t, err) || it.Len() == 0 {
+			break
+		}
+		for it.Next() {
+			cursorComps = append(cursorComps, it.Completion())
+		}
+		cursor = it.Cursor()
+	}
+	assert.Equal(t, allComps, cursorComps)
+
+	_, err := res.HitsAfter("invalid", limit)
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	it := res.HitsPage(0, 1)
+	it.Next()
+	_, err = res.CompletionsAfter(it.Cursor(), limit)
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestCompletionScorer(t *testing.T) {
+	index, docs := createIndex("files/books.txt.gz")
+
+	sdocs := make([][]string, len(docs))
+	for i, d := range docs {
+		sdocs[i] = strings.Fields(d)
+	}
+
+	scorers := []CompletionScorer{ByHits, ByMaxRank, BySumRank}
+	for _, q := range []string{"th", "the w", "a"} {
+		query := strings.Fields(q)
+		lastq := query[len(query)-1]
+
+		// Compute the expected completion stats. Ranks are
+		// normalized so that the first document has the
+		// highest rank.
+		stats := map[string]*CompletionStats{}
+		for i, d := range sdocs {
+			if !matches(query, d) {
+				continue
+			}
+
+			rank := len(docs) - 1 - i
+			for _, w := range prefixes(lastq, d) {
+				s := stats[w]
+				if s == nil {
+					s = &CompletionStats{Word: w}
+					stats[w] = s
+				}
+
+				s.Hits++
+				s.SumRank += rank
+				if rank > s.MaxRank {
+					s.MaxRank = rank
+				}
+			}
+		}
+
+		for _, scorer := range scorers {
+			expected := make([]Completion, 0, len(stats))
+			for _, s := range stats {
+				expected = append(expected, Completion{s.Word, s.Hits})
+			}
+			sort.Slice(expected, func(i, j int) bool {
+				si := scorer(*stats[expected[i].Word])
+				sj := scorer(*stats[expected[j].Word])
+				if si != sj {
+					return si > sj
+				}
+				return expected[i].Word < expected[j].Word
+			})
+
+			res := &Result{}
+			res.SetCompletionScorer(scorer)
+			index.Search(query, res)
+
+			actual := []Completion{}
+			for it := res.Completions(); it.Next(); {
+				actual = append(actual, it.Completion())
+			}
+
+			top := []Completion{}
+			for it := res.TopCompletions(10); it.Next(); {
+				top = append(top, it.Completion())
+			}
+
+			assert.Equal(t, expected, actual)
+			assert.Equal(t, expected[:min(10, len(expected))], top)
+		}
+	}
+}
+
+func TestResultPath(t *testing.T) {
+	b := NewBuilder()
+	b.Add(1, []string{"star", "wars"}, 10)
+	b.Add(2, []string{"star", "trek"}, 5)
+	idx := b.Build()
+
+	res := &Result{}
+	res.SetHistory(10, 1<<20)
+
+	queries := []struct {
+		query string
+		path  SearchPath
+	}{
+		{"s", FullSearch},
+		{"st", Continuation},
+		{"star w", Continuation},
+		{"st", Restored},
+		{"trek", FullSearch},
+	}
+
+	for _, q := range queries {
+		idx.Search(strings.Fields(q.query), res)
+		assert.Equal(t, q.path, res.Path(), q.query)
+	}
+	assert.Equal(t, "full", FullSearch.String())
+}
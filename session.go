@@ -0,0 +1,194 @@
+package hyb
+
+import (
+	"container/list"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// SessionCache keeps the results of the sessions of
+// a multi-user server, so that each session can pass
+// its previous result to Index.Search. It discards the
+// least recently used sessions to stay within its limits.
+// It is safe for concurrent use.
+type SessionCache struct {
+	mu sync.Mutex
+
+	// lru is ordered from the most to
+	// the least recently used session.
+	lru      *list.List
+	sessions map[string]*list.Element
+
+	maxSessions int
+	ttl         time.Duration
+	maxBytes    int
+	size        int
+
+	historyDepth int
+	historyBytes int
+
+	// now returns the current time.
+	// It is replaced in tests.
+	now func() time.Time
+}
+
+type session struct {
+	id     string
+	result *Result
+	size   int
+	used   time.Time
+}
+
+// NewSessionCache creates a cache that keeps at most
+// maxSessions sessions whose results use about maxBytes
+// bytes. Sessions that are unused for longer than ttl
+// expire. A zero or negative value removes the limit.
+func NewSessionCache(maxSessions int, ttl time.Duration, maxBytes int) *SessionCache {
+	return &SessionCache{
+		lru:         list.New(),
+		sessions:    map[string]*list.Element{},
+		maxSessions: max(maxSessions, 0),
+		ttl:         ttl,
+		maxBytes:    max(maxBytes, 0),
+		now:         time.Now,
+	}
+}
+
+// SetHistory sets the history of the results
+// created by the cache. See Result.SetHistory.
+func (c *SessionCache) SetHistory(depth, maxBytes int) {
+	c.mu.Lock()
+	c.historyDepth = depth
+	c.historyBytes = maxBytes
+	c.mu.Unlock()
+}
+
+// Acquire removes the result of a session from the cache
+// and returns it. If the session has no result or it has
+// expired, it returns a new result. Call Release when done
+// with the result to put it back. Since the result is not
+// in the cache until then, concurrent requests of the same
+// session get different results.
+func (c *SessionCache) Acquire(id string) *Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if e := c.sessions[id]; e != nil {
+		s := e.Value.(*session)
+		c.remove(e)
+
+		if !c.expired(s, now) {
+			return s.result
+		}
+	}
+
+	r := &Result{}
+	r.SetHistory(c.historyDepth, c.historyBytes)
+
+	return r
+}
+
+// Release puts the result of a session back to the cache
+// and marks the session as the most recently used. If the
+// session already has a result, it is replaced.
+func (c *SessionCache) Release(id string, r *Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e := c.sessions[id]; e != nil {
+		c.remove(e)
+	}
+
+	s := &session{
+		id:     id,
+		result: r,
+		size:   r.memSize() + len(id),
+		used:   c.now(),
+	}
+	if c.maxBytes > 0 && s.size > c.maxBytes {
+		return
+	}
+
+	c.sessions[id] = c.lru.PushFront(s)
+	c.size += s.size
+	c.evict(s.used)
+}
+
+// Remove discards the result of a session.
+func (c *SessionCache) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e := c.sessions[id]; e != nil {
+		c.remove(e)
+	}
+}
+
+// Clear discards the results of all the sessions.
+func (c *SessionCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.sessions = map[string]*list.Element{}
+	c.size = 0
+}
+
+// Len returns the number of sessions in the cache.
+func (c *SessionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Size returns the approximate number of
+// bytes used by the results in the cache.
+func (c *SessionCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+// evict removes the least recently used sessions until
+// the cache is within its limits and the expired sessions.
+func (c *SessionCache) evict(now time.Time) {
+	for e := c.lru.Back(); e != nil; e = c.lru.Back() {
+		full := (c.maxSessions > 0 && c.lru.Len() > c.maxSessions) ||
+			(c.maxBytes > 0 && c.size > c.maxBytes)
+
+		if !full && !c.expired(e.Value.(*session), now) {
+			break
+		}
+		c.remove(e)
+	}
+}
+
+func (c *SessionCache) expired(s *session, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(s.used) > c.ttl
+}
+
+func (c *SessionCache) remove(e *list.Element) {
+	s := c.lru.Remove(e).(*session)
+	delete(c.sessions, s.id)
+	c.size -= s.size
+}
+
+// memSize returns the approximate memory used by r.
+func (r *Result) memSize() int {
+	size := int(unsafe.Sizeof(*r))
+	size += cap(r.results) * int(unsafe.Sizeof(iposting{}))
+	size += cap(r.compbuf) * int(unsafe.Sizeof(completion{}))
+	size += r.history.size
+	for _, q := range r.query {
+		size += len(q)
+	}
+	for _, c := range r.conds {
+		size += len(c)
+	}
+
+	return size
+}
@@ -0,0 +1,110 @@
+package hyb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionCache(t *testing.T) {
+	b := NewBuilder()
+	b.Add(1, []string{"star", "wars"}, 10)
+	b.Add(2, []string{"star", "trek"}, 5)
+	b.Add(3, []string{"alien"}, 7)
+	idx := b.Build()
+
+	c := NewSessionCache(2, time.Minute, 0)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	search := func(id, query string) SearchPath {
+		r := c.Acquire(id)
+		idx.Search(strings.Fields(query), r)
+		c.Release(id, r)
+
+		return r.Path()
+	}
+
+	assert.Equal(t, FullSearch, search("a", "s"))
+	assert.Equal(t, Continuation, search("a", "st"))
+	assert.Equal(t, FullSearch, search("b", "a"))
+	assert.Equal(t, Continuation, search("a", "sta"))
+	assert.Equal(t, 2, c.Len())
+	assert.True(t, c.Size() > 0)
+
+	// The least recently used session is discarded
+	assert.Equal(t, FullSearch, search("c", "t"))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, FullSearch, search("b", "al"))
+	assert.Equal(t, Continuation, search("c", "tr"))
+
+	// Expired sessions start over
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, FullSearch, search("c", "tre"))
+	assert.Equal(t, 1, c.Len())
+
+	c.Remove("c")
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, 0, c.Size())
+
+	search("a", "s")
+	search("b", "s")
+	c.Clear()
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, 0, c.Size())
+	assert.Equal(t, FullSearch, search("a", "st"))
+
+	// Results with history
+	c.SetHistory(10, 1<<20)
+	search("d", "s")
+	search("d", "st")
+	assert.Equal(t, Restored, search("d", "s"))
+}
+
+func TestSessionCacheMemory(t *testing.T) {
+	r := &Result{}
+	size := r.memSize() + 1
+
+	// Only one empty result fits
+	c := NewSessionCache(0, 0, size)
+	c.Release("a", &Result{})
+	c.Release("b", &Result{})
+	assert.Equal(t, 1, c.Len())
+	assert.Equal(t, size, c.Size())
+
+	// Results that do not fit are discarded
+	big := &Result{compbuf: make([]completion, 100)}
+	c.Release("c", big)
+	assert.Equal(t, 1, c.Len())
+	assert.False(t, c.Acquire("c") == big)
+}
+
+func TestSessionCacheConcurrent(t *testing.T) {
+	index, docs := createIndex("files/movies.txt.gz")
+	c := NewSessionCache(8, time.Minute, 1<<20)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			id := fmt.Sprint(i % 4)
+			for _, d := range docs[i*10 : i*10+10] {
+				for _, q := range substrings(d) {
+					r := c.Acquire(id)
+					index.Search(strings.Fields(q), r)
+					c.Release(id, r)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, c.Len() <= 4)
+	assert.True(t, c.Size() <= 1<<20)
+}
@@ -0,0 +1,434 @@
+package hyb
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"sync"
+)
+
+// shardOf returns the shard of a document given its ID.
+// The ID is hashed so that consecutive IDs are spread
+// evenly across the shards.
+func shardOf(id, nshards int) int {
+	h := uint64(id) * 0x9e3779b97f4a7c15
+	h ^= h >> 32
+
+	return int(h % uint64(nshards))
+}
+
+// ShardedBuilder partitions the added documents by the
+// hash of their IDs into a number of builders, one for
+// each shard of the index. The ranks of the documents
+// are normalized across the shards, so the shards rank
+// the documents the same as a single index would.
+type ShardedBuilder struct {
+	shards []*Builder
+	count  int
+	err    error
+}
+
+// NewShardedBuilder creates an empty builder for
+// an index with the given number of shards.
+func NewShardedBuilder(nshards int) *ShardedBuilder {
+	b := &ShardedBuilder{shards: make([]*Builder, max(nshards, 1))}
+	for i := range b.shards {
+		b.shards[i] = NewBuilder()
+	}
+
+	return b
+}
+
+// Shard returns the builder of the ith shard. It
+// can be used to configure the shard, for example,
+// to set its memory limit.
+func (b *ShardedBuilder) Shard(i int) *Builder {
+	return b.shards[i]
+}
+
+// EnablePhrases enables the phrases of every shard.
+// See Builder.EnablePhrases.
+func (b *ShardedBuilder) EnablePhrases(n, minDocs int) {
+	for _, s := range b.shards {
+		s.EnablePhrases(n, minDocs)
+	}
+}
+
+// AddQuery adds a query to the query log of every
+// shard. See Builder.AddQuery.
+func (b *ShardedBuilder) AddQuery(query string, count int) {
+	for _, s := range b.shards {
+		s.AddQuery(query, count)
+	}
+}
+
+// SetQueryWeight sets the query weight of every
+// shard. See Builder.SetQueryWeight.
+func (b *ShardedBuilder) SetQueryWeight(w float64) {
+	for _, s := range b.shards {
+		s.SetQueryWeight(w)
+	}
+}
+
+// SetWorkers sets the number of goroutines used by
+// each shard. See Builder.SetWorkers.
+func (b *ShardedBuilder) SetWorkers(n int) {
+	for _, s := range b.shards {
+		s.SetWorkers(n)
+	}
+}
+
+// SetTieBreak sets the tie-break policy of
+// every shard. See Builder.SetTieBreak.
+func (b *ShardedBuilder) SetTieBreak(t TieBreak) {
+	for _, s := range b.shards {
+		s.SetTieBreak(t)
+	}
+}
+
+// Add adds a document to its shard.
+// See Builder.Add.
+func (b *ShardedBuilder) Add(id int, keywords []string, rank int) {
+	b.shard(id).Add(id, keywords, rank)
+}
+
+// Delete deletes a document from its shard.
+// See Builder.Delete.
+func (b *ShardedBuilder) Delete(id int) {
+	b.shard(id).Delete(id)
+}
+
+// shard returns the builder of the shard of a
+// document. Its insertion counter is set to that of
+// the sharded builder so that the counters of the
+// shards follow the order of every Add and Delete.
+func (b *ShardedBuilder) shard(id int) *Builder {
+	s := b.shards[shardOf(id, len(b.shards))]
+	s.count = b.count
+	b.count++
+
+	return s
+}
+
+// Err returns the first error that
+// occurred while building the shards.
+func (b *ShardedBuilder) Err() error {
+	return b.err
+}
+
+// Build builds the shards concurrently. It returns
+// nil if any of the shards cannot be built. The
+// tie-break policy and the query weight of the
+// first shard apply to every shard.
+func (b *ShardedBuilder) Build() *ShardedIndex {
+	states := make([]*buildState, len(b.shards))
+	b.each(func(i int, s *Builder) {
+		states[i] = s.collect()
+	})
+
+	defer func() {
+		for i, s := range b.shards {
+			if states[i] != nil {
+				s.close(states[i])
+			}
+		}
+	}()
+
+	if b.checkErr() != nil {
+		return nil
+	}
+
+	wordpop := b.normalize(states)
+
+	shards := make([]*Index, len(b.shards))
+	b.each(func(i int, s *Builder) {
+		shards[i] = s.finish(states[i], wordpop)
+	})
+
+	if b.checkErr() != nil {
+		return nil
+	}
+
+	return NewShardedIndex(shards...)
+}
+
+// each calls fn for every shard concurrently.
+func (b *ShardedBuilder) each(fn func(i int, s *Builder)) {
+	wg := sync.WaitGroup{}
+	for i, s := range b.shards {
+		wg.Add(1)
+		go func(i int, s *Builder) {
+			defer wg.Done()
+			fn(i, s)
+		}(i, s)
+	}
+	wg.Wait()
+}
+
+// checkErr sets and returns the
+// first error of the shards.
+func (b *ShardedBuilder) checkErr() error {
+	for _, s := range b.shards {
+		if err := s.Err(); err != nil {
+			b.err = err
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalize normalizes the ranks of the documents of
+// every shard together and blends the query log
+// popularity into them, the same as Builder.Build
+// does for a single index. It returns the query log
+// popularity of the words, or nil if there is no
+// query log.
+func (b *ShardedBuilder) normalize(states []*buildState) map[string]int {
+	first := b.shards[0]
+	workers := first.numWorkers()
+	ties := first.opts.TieBreak
+
+	// Merge the docs of the shards by ID
+	type ref struct {
+		shard int
+		i     int
+	}
+
+	refs := []ref{}
+	matchers := make([]*queryMatcher, len(states))
+	for si, s := range states {
+		for i := range s.ids {
+			refs = append(refs, ref{si, i})
+		}
+		matchers[si] = s.queries
+	}
+
+	id := func(r ref) int { return states[r.shard].ids[r.i] }
+	parallelSort(refs, func(a, b ref) bool { return id(a) < id(b) }, workers)
+
+	ranks := make([]int, len(refs))
+	docpop := make([]int, len(refs))
+	counts := []int{}
+	if ties == InsertionOrder {
+		counts = make([]int, len(refs))
+	}
+
+	hasQueries := false
+	for j, r := range refs {
+		s := states[r.shard]
+		ranks[j] = s.ranks[r.i]
+		if len(counts) > 0 {
+			counts[j] = s.counts[r.i]
+		}
+		if s.queries != nil {
+			docpop[j] = s.docpop[r.i]
+			hasQueries = true
+		}
+	}
+
+	normalizeRanks(ranks, counts, ties, workers)
+
+	var wordpop map[string]int
+	if hasQueries {
+		blendRanks(ranks, docpop, first.queryWeight, counts, ties, workers)
+		wordpop = mergeWordPopularity(matchers)
+	}
+
+	for j, r := range refs {
+		states[r.shard].ranks[r.i] = ranks[j]
+	}
+
+	return wordpop
+}
+
+// ShardedIndex is an index whose documents are
+// partitioned into several indexes. The shards are
+// searched concurrently and their results merged.
+type ShardedIndex struct {
+	shards []*Index
+}
+
+// NewShardedIndex creates a sharded index from its shards.
+// The documents must be partitioned and ranked the same
+// way as in ShardedBuilder, so the order of the shards
+// matters.
+func NewShardedIndex(shards ...*Index) *ShardedIndex {
+	return &ShardedIndex{shards}
+}
+
+// Shards returns the shards of the index.
+func (idx *ShardedIndex) Shards() []*Index {
+	return idx.shards
+}
+
+// Size returns the total size of the shards in bytes.
+func (idx *ShardedIndex) Size() int {
+	size := 0
+	for _, s := range idx.shards {
+		size += s.Size()
+	}
+
+	return size
+}
+
+// Search searches every shard concurrently. Like
+// Index.Search, prev should point to the result of
+// the previous search to speed up continuations.
+func (idx *ShardedIndex) Search(query []string, prev *ShardedResult) {
+	if len(prev.results) != len(idx.shards) {
+		prev.results = make([]*Result, len(idx.shards))
+		for i := range prev.results {
+			prev.results[i] = &Result{scorer: prev.scorer}
+		}
+	}
+	prev.index = idx
+
+	wg := sync.WaitGroup{}
+	for i, s := range idx.shards {
+		wg.Add(1)
+		go func(s *Index, r *Result) {
+			defer wg.Done()
+			s.Search(query, r)
+		}(s, prev.results[i])
+	}
+	wg.Wait()
+}
+
+// ShardedResult contains the merged
+// search results of the shards.
+type ShardedResult struct {
+	index   *ShardedIndex
+	results []*Result
+	scorer  CompletionScorer
+}
+
+// Shards returns the results of each shard.
+func (r *ShardedResult) Shards() []*Result {
+	return r.results
+}
+
+// SetCompletionScorer sets the function used to order
+// the completions of every shard and of the merged
+// completions. See Result.SetCompletionScorer.
+func (r *ShardedResult) SetCompletionScorer(s CompletionScorer) {
+	r.scorer = s
+	for _, res := range r.results {
+		res.SetCompletionScorer(s)
+	}
+}
+
+// Hits returns all the IDs that match the
+// query sorted the same way as TopHits.
+func (r *ShardedResult) Hits() *Hits {
+	n := 0
+	for _, res := range r.results {
+		n += len(res.results)
+	}
+
+	return r.TopHits(n)
+}
+
+// Total returns the number of documents
+// that match the query in every shard.
+func (r *ShardedResult) Total() int {
+	n := 0
+	for _, res := range r.results {
+		n += res.Total()
+	}
+
+	return n
+}
+
+// TopHits returns the top k hits of the shards. Since
+// the ranks are normalized across the shards, the hits
+// are in the same order as in a single index.
+func (r *ShardedResult) TopHits(k int) *Hits {
+	hits := []iposting{}
+	for _, res := range r.results {
+		hits = append(hits, res.topHits(k, nil)...)
+	}
+
+	sort.Sort(ranks(hits))
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+
+	return &Hits{hits, -1, ""}
+}
+
+// Completions returns all the word completions of
+// the last query word sorted the same way as
+// TopCompletions.
+func (r *ShardedResult) Completions() *Completions {
+	return r.TopCompletions(math.MaxInt)
+}
+
+// TopCompletions returns the top k completions of the
+// last query word sorted the same way as Result does.
+// Since each shard has its own word IDs, the hits and
+// ranks of a word are aggregated across the shards by
+// word before the completions are scored.
+func (r *ShardedResult) TopCompletions(k int) *Completions {
+	if k <= 0 {
+		return &Completions{nil, -1, nil, ""}
+	}
+
+	comps := map[string]completion{}
+	pops := map[string]uint32{}
+	for _, res := range r.results {
+		for _, c := range res.completions {
+			if c.hits == 0 {
+				continue
+			}
+
+			w := res.words[c.word]
+			a := comps[w]
+			a.hits += c.hits
+			a.maxRank = max(a.maxRank, c.maxRank)
+			a.sumRank += c.sumRank
+			comps[w] = a
+
+			// The popularity of a word
+			// is the same in every shard
+			if res.wordpop != nil {
+				pops[w] = res.wordpop[c.word]
+			}
+		}
+	}
+
+	words := make([]string, 0, len(comps))
+	for w := range comps {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+
+	// Score the completions like the
+	// result of a single index would
+	merged := &Result{words: words, scorer: r.scorer}
+	if len(pops) > 0 {
+		merged.wordpop = make([]uint32, len(words))
+		for i, w := range words {
+			merged.wordpop[i] = pops[w]
+		}
+	}
+
+	h := &compHeap{}
+	heap.Init(h)
+	for i, w := range words {
+		c := comps[w]
+		c.word = uint32(i)
+		c = merged.scored(c)
+
+		if h.Len() < k {
+			heap.Push(h, c)
+		} else if compBefore(c, h.Peek()) {
+			heap.Pop(h)
+			heap.Push(h, c)
+		}
+	}
+
+	sort.Sort(byScore(*h))
+
+	return &Completions{*h, -1, words, ""}
+}
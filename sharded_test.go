@@ -0,0 +1,153 @@
+package hyb
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedIndex(t *testing.T) {
+	index, docs := createIndex("files/books.txt.gz")
+
+	b := NewShardedBuilder(4)
+	rank := len(docs)
+	for i, d := range docs {
+		b.Add(i, strings.Fields(d), rank)
+		rank--
+	}
+	sindex := b.Build()
+	assert.Nil(t, b.Err())
+	assert.Len(t, sindex.Shards(), 4)
+
+	// The shards contain all the documents
+	ndocs := 0
+	for i, s := range sindex.Shards() {
+		ndocs += s.ndocs
+		assert.True(t, s.ndocs > len(docs)/8, "shard %d", i)
+	}
+	assert.Equal(t, len(docs), ndocs)
+
+	sortedIDs := func(hits *Hits) []int {
+		ids := []int{}
+		for hits.Next() {
+			ids = append(ids, hits.ID())
+		}
+		sort.Ints(ids)
+		return ids
+	}
+
+	allComps := func(it *Completions) []Completion {
+		comps := []Completion{}
+		for it.Next() {
+			comps = append(comps, it.Completion())
+		}
+		sort.Slice(comps, func(i, j int) bool { return comps[i].Word < comps[j].Word })
+		return comps
+	}
+
+Exit:
+	for _, d := range docs[:50] {
+		res := &Result{}
+		sres := &ShardedResult{}
+
+		for _, q := range substrings(d) {
+			query := strings.Fields(q)
+			index.Search(query, res)
+			sindex.Search(query, sres)
+
+			ok := assert.Equal(t, sortedIDs(res.Hits()), sortedIDs(sres.Hits()), q) &&
+				assert.Equal(t, allComps(res.Completions()), allComps(sres.Completions()), q)
+			if !ok {
+				break Exit
+			}
+
+			// Hits of the same shard are in rank order
+			last := map[int]int{}
+			for it := sres.TopHits(20); it.Next(); {
+				id := it.ID()
+				s := shardOf(id, 4)
+				if p, ok := last[s]; ok && !assert.True(t, p < id, q) {
+					break Exit
+				}
+				last[s] = id
+			}
+
+			top := sres.TopCompletions(3)
+			if all := sres.Completions(); all.Len() > 3 {
+				assert.Equal(t, 3, top.Len())
+			}
+		}
+	}
+}
+
+func TestShardedIndexRanks(t *testing.T) {
+	_, docs := createIndex("files/books.txt.gz")
+
+	for _, ties := range []TieBreak{DescendingID, InsertionOrder, KeepTies} {
+		b := NewBuilder()
+		sb := NewShardedBuilder(4)
+		for _, b := range []interface {
+			SetTieBreak(TieBreak)
+			AddQuery(string, int)
+		}{b, sb} {
+			b.SetTieBreak(ties)
+			b.AddQuery("the", 20)
+			b.AddQuery("a", 5)
+		}
+
+		// Add the documents in reverse order with
+		// duplicate ranks, and with most of the
+		// best documents in a single shard
+		for i := len(docs) - 1; i >= 0; i-- {
+			rank := i % 7
+			if shardOf(i, 4) == 0 {
+				rank += 5
+			}
+			b.Add(i, strings.Fields(docs[i]), rank)
+			sb.Add(i, strings.Fields(docs[i]), rank)
+		}
+		index := b.Build()
+		sindex := sb.Build()
+
+		comps := func(it *Completions) []Completion {
+			c := []Completion{}
+			for it.Next() {
+				c = append(c, it.Completion())
+			}
+			return c
+		}
+
+		for _, scorer := range []CompletionScorer{nil, ByMaxRank, BySumRank} {
+			for _, d := range docs[:20] {
+				res := &Result{}
+				res.SetCompletionScorer(scorer)
+				sres := &ShardedResult{}
+				sres.SetCompletionScorer(scorer)
+
+				for _, q := range substrings(d) {
+					query := strings.Fields(q)
+					index.Search(query, res)
+					sindex.Search(query, sres)
+
+					ok := assert.Equal(t, hitIDs(res.TopHits(20)), hitIDs(sres.TopHits(20)), q) &&
+						assert.Equal(t, comps(res.TopCompletions(10)), comps(sres.TopCompletions(10)), q)
+					if !ok {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestShardedIndexEmpty(t *testing.T) {
+	sindex := NewShardedBuilder(3).Build()
+
+	res := &ShardedResult{}
+	sindex.Search([]string{"a"}, res)
+	assert.Equal(t, 0, res.Hits().Len())
+	assert.Equal(t, 0, res.TopCompletions(5).Len())
+	assert.Equal(t, 0, sindex.Size())
+}
@@ -0,0 +1,468 @@
+package hyb
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// SetMemoryLimit makes the builder spill the added documents
+// to sorted temporary files in dir once they use more than
+// about limit bytes. Build then merges those files instead of
+// keeping all the documents in memory. If dir is empty, the
+// default directory for temporary files is used. A limit of
+// zero disables spilling, which is the default.
+//
+// The runs contain documents rather than postings since the
+// word IDs and the normalized ranks of the postings are only
+// known once every document was seen. Build reads the merged
+// runs twice and compresses the postings of the second pass
+// as soon as a chunk is filled, so it only keeps counters per
+// word and per document in memory. If Build is not called,
+// call Discard to remove the temporary files.
+func (b *Builder) SetMemoryLimit(limit int, dir string) {
+	b.memLimit = max(limit, 0)
+	b.tmpDir = dir
+}
+
+// memSize returns the approximate memory used by d.
+func (d *doc) memSize() int {
+	size := int(unsafe.Sizeof(*d))
+	for _, w := range d.words {
+		size += len(w) + int(unsafe.Sizeof(w))
+	}
+	for _, p := range d.phrases {
+		size += len(p) + int(unsafe.Sizeof(p))
+	}
+
+	return size
+}
+
+// spill writes the docs sorted by ID to a temporary
+// file and removes them from memory. Only the most
+// recent doc of each ID is written.
+func (b *Builder) spill() {
+	docs := b.docs
+	b.docs = []doc{}
+	b.memUsed = 0
+
+	if b.err != nil {
+		return
+	}
+
+	parallelSort(docs, byID, b.numWorkers())
+
+	file, err := os.CreateTemp(b.tmpDir, "hyb-run-*")
+	if err != nil {
+		b.err = fmt.Errorf("hyb: spill failed (%v)", err)
+		return
+	}
+
+	w := bufio.NewWriter(file)
+	buf := []byte{}
+	for i, d := range docs {
+		if i > 0 && d.id == docs[i-1].id {
+			continue
+		}
+
+		buf = appendDoc(buf[:0], d)
+		if _, err = w.Write(buf); err != nil {
+			break
+		}
+	}
+
+	err = checkErr(err, w.Flush(), file.Close())
+	if err != nil {
+		b.err = fmt.Errorf("hyb: spill failed (%v)", err)
+		os.Remove(file.Name())
+		return
+	}
+	b.runs = append(b.runs, file.Name())
+}
+
+// Discard drops the documents added to the builder and
+// removes the temporary files of the spilled ones. Build
+// does this itself, so Discard is only needed if Build is
+// not called, for example after an error from Import.
+func (b *Builder) Discard() {
+	b.docs = []doc{}
+	b.memUsed = 0
+
+	for _, name := range b.runs {
+		os.Remove(name)
+	}
+	b.runs = nil
+}
+
+// stream returns the docs of the builder sorted by ID
+// without the duplicate and deleted docs.
+func (b *Builder) stream() docStream {
+	if len(b.runs) == 0 {
+		// Sort by ascending ids
+		// and descending counter
+		parallelSort(b.docs, byID, b.numWorkers())
+
+		// Remove duplicates and deleted docs
+		pid := -1
+		docs := b.docs[:0]
+		for _, d := range b.docs {
+			if pid != d.id && !d.deleted {
+				docs = append(docs, d)
+			}
+			pid = d.id
+		}
+		b.docs = docs
+
+		return &sliceStream{docs: docs}
+	}
+
+	if len(b.docs) > 0 {
+		b.spill()
+	}
+
+	runs := b.runs
+	b.runs = nil
+
+	s := &mergeStream{runs: runs, tmpDir: b.tmpDir}
+	s.init()
+
+	return s
+}
+
+// docStream iterates over docs sorted by ID. It can be
+// rewound once the iteration is done to read the same
+// docs again.
+type docStream interface {
+	next() (doc, bool)
+	rewind() error
+	err() error
+	close() error
+}
+
+type sliceStream struct {
+	docs []doc
+	pos  int
+}
+
+func (s *sliceStream) next() (doc, bool) {
+	if s.pos >= len(s.docs) {
+		return doc{}, false
+	}
+
+	s.pos++
+	return s.docs[s.pos-1], true
+}
+
+func (s *sliceStream) rewind() error { s.pos = 0; return nil }
+func (s *sliceStream) err() error    { return nil }
+func (s *sliceStream) close() error  { return nil }
+
+// runReader reads the docs of a spilled run.
+type runReader struct {
+	file *os.File
+	r    *bufio.Reader
+	doc  doc
+}
+
+// byRun is a minimum heap of run readers
+// ordered by ascending ids and descending
+// counter of their current doc.
+type byRun []*runReader
+
+func (h byRun) Len() int           { return len(h) }
+func (h byRun) Less(i, j int) bool { return byID(h[i].doc, h[j].doc) }
+func (h byRun) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *byRun) Push(x interface{}) {
+	*h = append(*h, x.(*runReader))
+}
+
+func (h *byRun) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// mergeStream performs a k-way merge of the spilled runs.
+// The merged docs are written to another temporary file
+// which is read instead of the runs after a rewind.
+type mergeStream struct {
+	runs   []string
+	tmpDir string
+
+	heap    byRun
+	readers []*runReader
+	pid     int
+	started bool
+
+	merged *os.File
+	w      *bufio.Writer
+	r      *bufio.Reader
+	buf    []byte
+
+	e error
+}
+
+func (s *mergeStream) init() {
+	s.pid = -1
+
+	for _, name := range s.runs {
+		file, err := os.Open(name)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		rr := &runReader{file: file, r: bufio.NewReader(file)}
+		s.readers = append(s.readers, rr)
+		if s.advance(rr) {
+			s.heap = append(s.heap, rr)
+		}
+	}
+	heap.Init(&s.heap)
+
+	merged, err := os.CreateTemp(s.tmpDir, "hyb-merged-*")
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.merged = merged
+	s.w = bufio.NewWriter(merged)
+}
+
+// advance reads the next doc of a run. It returns
+// false if there are no more docs or on error.
+func (s *mergeStream) advance(rr *runReader) bool {
+	d, err := readDoc(rr.r)
+	if err == io.EOF {
+		return false
+	} else if err != nil {
+		s.fail(err)
+		return false
+	}
+
+	rr.doc = d
+	return true
+}
+
+func (s *mergeStream) next() (doc, bool) {
+	if s.e != nil {
+		return doc{}, false
+	}
+
+	// Read the merged file after a rewind
+	if s.r != nil {
+		d, err := readDoc(s.r)
+		if err != nil {
+			if err != io.EOF {
+				s.fail(err)
+			}
+			return doc{}, false
+		}
+
+		return d, true
+	}
+
+	for s.heap.Len() > 0 {
+		rr := s.heap[0]
+		d := rr.doc
+		if s.advance(rr) {
+			heap.Fix(&s.heap, 0)
+		} else {
+			heap.Pop(&s.heap)
+		}
+
+		// Skip older duplicates and deleted docs
+		if s.started && d.id == s.pid {
+			continue
+		}
+		s.pid = d.id
+		s.started = true
+
+		if d.deleted {
+			continue
+		}
+
+		s.buf = appendDoc(s.buf[:0], d)
+		if _, err := s.w.Write(s.buf); err != nil {
+			s.fail(err)
+			return doc{}, false
+		}
+
+		return d, true
+	}
+
+	return doc{}, false
+}
+
+func (s *mergeStream) rewind() error {
+	if s.e != nil {
+		return s.e
+	}
+
+	if s.r != nil {
+		if _, err := s.merged.Seek(0, io.SeekStart); err != nil {
+			s.fail(err)
+			return s.e
+		}
+		s.r.Reset(s.merged)
+
+		return nil
+	}
+
+	if err := s.w.Flush(); err != nil {
+		s.fail(err)
+		return s.e
+	}
+
+	if _, err := s.merged.Seek(0, io.SeekStart); err != nil {
+		s.fail(err)
+		return s.e
+	}
+	s.r = bufio.NewReader(s.merged)
+	if err := s.closeRuns(); err != nil {
+		s.fail(err)
+		return s.e
+	}
+
+	return nil
+}
+
+func (s *mergeStream) err() error {
+	return s.e
+}
+
+// close closes and removes all the temporary files.
+func (s *mergeStream) close() error {
+	err := s.closeRuns()
+	if s.merged != nil {
+		err = checkErr(err, s.merged.Close(), os.Remove(s.merged.Name()))
+		s.merged = nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("hyb: cleanup failed (%v)", err)
+	}
+
+	return nil
+}
+
+func (s *mergeStream) closeRuns() error {
+	var err error
+	for _, rr := range s.readers {
+		err = checkErr(err, rr.file.Close())
+	}
+	s.readers = nil
+
+	for _, name := range s.runs {
+		if e := os.Remove(name); e != nil && !errors.Is(e, os.ErrNotExist) {
+			err = checkErr(err, e)
+		}
+	}
+	s.runs = nil
+
+	return err
+}
+
+func (s *mergeStream) fail(err error) {
+	if s.e == nil {
+		s.e = fmt.Errorf("hyb: merge failed (%v)", err)
+	}
+}
+
+// appendDoc appends the binary encoding of d to buf.
+func appendDoc(buf []byte, d doc) []byte {
+	buf = binary.AppendVarint(buf, int64(d.id))
+	buf = binary.AppendVarint(buf, int64(d.rank))
+	buf = binary.AppendUvarint(buf, uint64(d.count))
+
+	deleted := byte(0)
+	if d.deleted {
+		deleted = 1
+	}
+	buf = append(buf, deleted)
+
+	for _, strs := range [][]string{d.words, d.phrases} {
+		buf = binary.AppendUvarint(buf, uint64(len(strs)))
+		for _, s := range strs {
+			buf = binary.AppendUvarint(buf, uint64(len(s)))
+			buf = append(buf, s...)
+		}
+	}
+
+	return buf
+}
+
+// readDoc decodes a doc encoded by appendDoc. It
+// returns io.EOF if there are no more docs to read.
+func readDoc(r *bufio.Reader) (doc, error) {
+	d := doc{}
+
+	id, err := binary.ReadVarint(r)
+	if err != nil {
+		return d, err
+	}
+
+	rank, err := binary.ReadVarint(r)
+	if err != nil {
+		return d, unexpected(err)
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return d, unexpected(err)
+	}
+
+	deleted, err := r.ReadByte()
+	if err != nil {
+		return d, unexpected(err)
+	}
+
+	d.id = int(id)
+	d.rank = int(rank)
+	d.count = int(count)
+	d.deleted = deleted == 1
+
+	for _, strs := range []*[]string{&d.words, &d.phrases} {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return d, unexpected(err)
+		}
+
+		if n > 0 {
+			*strs = make([]string, n)
+		}
+
+		for i := range *strs {
+			l, err := binary.ReadUvarint(r)
+			if err != nil {
+				return d, unexpected(err)
+			}
+
+			b := make([]byte, l)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return d, unexpected(err)
+			}
+			(*strs)[i] = string(b)
+		}
+	}
+
+	return d, nil
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF
+// since a doc is only partially read.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,115 @@
+package hyb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSpill(t *testing.T) {
+	file, err := os.Open("files/books.txt.gz")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	fgzip, err := gzip.NewReader(file)
+	assert.Nil(t, err)
+	defer fgzip.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(fgzip)
+	for scanner.Scan() {
+		lines = append(lines, strings.ToLower(scanner.Text()))
+	}
+
+	dir := t.TempDir()
+	build := func(b *Builder) *Index {
+		b.EnablePhrases(2, 2)
+		b.AddQuery("the", 10)
+		b.AddQuery("harry pot", 5)
+
+		for i := 0; i < 100; i++ {
+			b.Delete(i)
+		}
+
+		for i, l := range lines {
+			b.Add(i, strings.Fields(l), i)
+		}
+
+		// Overwrite and delete some items
+		b.Add(42, strings.Fields("the answer to life"), 42)
+		for i := 50; i < 100; i++ {
+			b.Delete(i)
+		}
+
+		return b.Build()
+	}
+
+	expected := build(NewBuilder())
+
+	b := NewBuilder()
+	b.SetMemoryLimit(64<<10, dir)
+	actual := build(b)
+	assert.Nil(t, b.Err())
+
+	assert.Equal(t, expected.words, actual.words)
+	assert.Equal(t, expected.freqword, actual.freqword)
+	assert.Equal(t, expected.charfreq, actual.charfreq)
+	assert.Equal(t, expected.phrases, actual.phrases)
+	assert.Equal(t, expected.wordpop, actual.wordpop)
+	assert.Equal(t, expected.size, actual.size)
+	assert.Equal(t, parseIndex(expected), parseIndex(actual))
+
+	// Temporary files should be removed
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestBuildSpillError(t *testing.T) {
+	b := NewBuilder()
+	b.SetMemoryLimit(1, filepath.Join(t.TempDir(), "missing"))
+	b.Add(0, []string{"abc"}, 0)
+	b.Add(1, []string{"abd"}, 1)
+
+	assert.NotNil(t, b.Err())
+	assert.Nil(t, b.Build())
+	assert.NotNil(t, b.Err())
+}
+
+func TestBuildSpillCleanup(t *testing.T) {
+	dir := t.TempDir()
+	b := NewBuilder()
+	b.SetMemoryLimit(1, dir)
+	b.Add(0, []string{"abc"}, 0)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	// A failed spill keeps the earlier runs
+	// until Build removes them
+	b.tmpDir = filepath.Join(dir, "missing")
+	b.Add(1, []string{"abd"}, 1)
+	assert.NotNil(t, b.Err())
+	assert.Nil(t, b.Build())
+
+	files, err = filepath.Glob(filepath.Join(dir, "*"))
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+
+	// Discard removes the runs without a Build
+	b = NewBuilder()
+	b.SetMemoryLimit(1, dir)
+	b.Add(0, []string{"abc"}, 0)
+	b.Add(1, []string{"abd"}, 1)
+	b.Discard()
+
+	files, err = filepath.Glob(filepath.Join(dir, "*"))
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,201 @@
+package hyb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/robskie/bp128"
+)
+
+// StreamStats contains the compressed sizes in
+// bytes of the streams of a group of postings.
+type StreamStats struct {
+	IDs   int
+	Words int
+	Ranks int
+}
+
+// Total returns the size of the streams.
+func (s StreamStats) Total() int {
+	return s.IDs + s.Words + s.Ranks
+}
+
+func (s *StreamStats) add(o StreamStats) {
+	s.IDs += o.IDs
+	s.Words += o.Words
+	s.Ranks += o.Ranks
+}
+
+// BlockStats describes a block of postings.
+type BlockStats struct {
+	// FirstWord and LastWord are the
+	// first and last words of the block.
+	FirstWord string
+	LastWord  string
+
+	Postings int
+	Chunks   int
+	Bytes    StreamStats
+}
+
+// Stats describes the contents of an index.
+type Stats struct {
+	Words     int
+	Documents int
+	Postings  int
+	Chunks    int
+	Blocks    []BlockStats
+
+	// Bytes is the compressed
+	// size of all the postings.
+	Bytes StreamStats
+
+	// CharFreqDepth is the number of character
+	// positions in the table used to estimate
+	// the number of results of a query.
+	CharFreqDepth int
+
+	// Phrases is the number of phrases and
+	// Prefixes is the number of prefixes with
+	// precomputed results.
+	Phrases  int
+	Prefixes int
+
+	// Size is the same as Index.Size.
+	Size int
+}
+
+// Stats returns the statistics of the index.
+func (idx *Index) Stats() Stats {
+	s := Stats{
+		Words:     len(idx.words),
+		Documents: idx.ndocs,
+		Blocks:    make([]BlockStats, len(idx.blocks)),
+		Phrases:   len(idx.phrases.phrases),
+		Prefixes:  len(idx.prefixes.prefixes),
+		Size:      idx.size,
+	}
+	if len(idx.charfreq) > 0 {
+		s.CharFreqDepth = len(idx.charfreq[0])
+	}
+
+	for i, b := range idx.blocks {
+		bs := BlockStats{
+			FirstWord: b.wboundary[0],
+			LastWord:  b.wboundary[1],
+			Chunks:    len(b.posts),
+		}
+		for _, p := range b.posts {
+			bs.Postings += p.ids.Len()
+			bs.Bytes.add(StreamStats{
+				IDs:   p.ids.Size(),
+				Words: p.words.Size(),
+				Ranks: p.ranks.Size(),
+			})
+		}
+
+		s.Blocks[i] = bs
+		s.Postings += bs.Postings
+		s.Chunks += bs.Chunks
+		s.Bytes.add(bs.Bytes)
+	}
+
+	return s
+}
+
+// WordStats returns the number of documents that contain
+// the word and the number of its postings, which is more
+// if a document has the word more than once. It returns
+// zeros if the word is not in the index. It decodes the
+// postings of the block of the word, so it is meant
+// for inspection rather than searching.
+func (idx *Index) WordStats(word string) (docs, postings int) {
+	wrange := getWordRange(word, idx.words, 0)
+	if wrange == nil || idx.words[wrange[0]] != word {
+		return 0, 0
+	}
+	wid := int(wrange[0])
+
+	buffer := []uint32{}
+	chunkSize := idx.chunkSize()
+	bp128.MakeAlignedSlice(chunkSize*2, &buffer)
+
+	ids := buffer[0:]
+	words := ids[chunkSize:]
+
+	pid := uint32(math.MaxUint32)
+	for _, b := range idx.blocks {
+		if wid < b.boundary[0] || wid > b.boundary[1] {
+			continue
+		}
+
+		for _, p := range b.posts {
+			bp128.Unpack(p.ids, &ids)
+			bp128.Unpack(p.words, &words)
+
+			for j, id := range ids {
+				if int(idx.freqword[words[j]]) != wid {
+					continue
+				}
+
+				postings++
+				if id != pid {
+					docs++
+				}
+				pid = id
+			}
+		}
+	}
+
+	return docs, postings
+}
+
+// Dump writes a human readable listing of the words
+// and the postings of each block to w. Each posting
+// is written as a line of document ID, word and rank
+// separated by tabs. It is meant for debugging.
+func (idx *Index) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "words %d\n", len(idx.words))
+	for i, word := range idx.words {
+		fmt.Fprintf(bw, "%d\t%s\n", i, word)
+	}
+
+	buffer := []uint32{}
+	chunkSize := idx.chunkSize()
+	bp128.MakeAlignedSlice(chunkSize*3, &buffer)
+
+	ids := buffer[0:]
+	words := ids[chunkSize:]
+	ranks := words[chunkSize:]
+
+	for i, b := range idx.blocks {
+		fmt.Fprintf(
+			bw,
+			"block %d %s %s\n",
+			i,
+			b.wboundary[0],
+			b.wboundary[1],
+		)
+
+		for _, p := range b.posts {
+			bp128.Unpack(p.ids, &ids)
+			bp128.Unpack(p.words, &words)
+			bp128.Unpack(p.ranks, &ranks)
+
+			for j, id := range ids {
+				word := idx.words[idx.freqword[words[j]]]
+				fmt.Fprintf(bw, "%d\t%s\t%d\n", id, word, ranks[j])
+			}
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("hyb: dump failed (%v)", err)
+	}
+
+	return nil
+}
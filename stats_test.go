@@ -0,0 +1,60 @@
+package hyb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexStats(t *testing.T) {
+	b := NewBuilder()
+	b.Add(1, []string{"star", "wars"}, 10)
+	b.Add(2, []string{"star", "trek"}, 5)
+	b.Add(3, []string{"alien", "alien"}, 7)
+	idx := b.Build()
+
+	s := idx.Stats()
+	assert.Equal(t, 4, s.Words)
+	assert.Equal(t, 3, s.Documents)
+	assert.Equal(t, 6, s.Postings)
+	assert.Equal(t, idx.Size(), s.Size)
+	assert.True(t, s.CharFreqDepth > 0)
+
+	postings, chunks, nbytes := 0, 0, 0
+	for _, bs := range s.Blocks {
+		assert.True(t, bs.FirstWord <= bs.LastWord)
+		assert.True(t, bs.Chunks > 0)
+		assert.True(t, bs.Bytes.IDs > 0 && bs.Bytes.Words > 0 && bs.Bytes.Ranks > 0)
+		postings += bs.Postings
+		chunks += bs.Chunks
+		nbytes += bs.Bytes.Total()
+	}
+	assert.Equal(t, s.Postings, postings)
+	assert.Equal(t, s.Chunks, chunks)
+	assert.Equal(t, s.Bytes.Total(), nbytes)
+	assert.True(t, s.Bytes.Total() < s.Size)
+
+	docs, posts := idx.WordStats("star")
+	assert.Equal(t, 2, docs)
+	assert.Equal(t, 2, posts)
+	docs, posts = idx.WordStats("alien")
+	assert.Equal(t, 1, docs)
+	assert.Equal(t, 2, posts)
+	docs, posts = idx.WordStats("sta")
+	assert.Equal(t, 0, docs)
+	assert.Equal(t, 0, posts)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, idx.Dump(buf))
+
+	dump := buf.String()
+	assert.True(t, strings.HasPrefix(dump, "words 4\n0\talien\n"))
+	assert.Contains(t, dump, "1\twars\t2\n")
+	assert.Contains(t, dump, "3\talien\t1\n")
+
+	empty := NewIndex().Stats()
+	assert.Equal(t, 0, empty.Words)
+	assert.Empty(t, empty.Blocks)
+}
@@ -0,0 +1,89 @@
+package hyb
+
+// TieBreak selects the hit order of documents
+// that were added with the same rank.
+type TieBreak int
+
+// Tie-break policies.
+const (
+	// DescendingID gives the documents with greater
+	// IDs a higher rank. This is the default.
+	DescendingID TieBreak = iota
+
+	// AscendingID gives the documents with smaller
+	// IDs a higher rank.
+	AscendingID
+
+	// InsertionOrder gives the documents that were
+	// added earlier a higher rank. A document that
+	// replaces another one takes the place of the
+	// latest Add.
+	InsertionOrder
+
+	// KeepTies gives documents with the same rank
+	// the same normalized rank instead of unique
+	// ones. TopHits returns them by ascending ID.
+	KeepTies
+)
+
+func (t TieBreak) String() string {
+	switch t {
+	case DescendingID:
+		return "id-desc"
+	case AscendingID:
+		return "id-asc"
+	case InsertionOrder:
+		return "insertion"
+	case KeepTies:
+		return "keep"
+	}
+
+	return "unknown"
+}
+
+// SetTieBreak sets how Build orders the documents that have
+// the same rank. Since an index only stores the order of the
+// ranks, Build replaces each rank with the position of the
+// document when sorted by rank, and the policy decides the
+// positions of equal ranks. The policy also applies to the
+// ranks blended with the query log, see SetQueryWeight. It
+// is the TieBreak field of the options, see SetOptions, so
+// it is recorded in the index and kept by MergeIndexes.
+func (b *Builder) SetTieBreak(t TieBreak) {
+	b.opts.TieBreak = t
+}
+
+// before returns a function that reports whether
+// the ath document gets a lower position than the
+// bth when their ranks are equal. Since docs are
+// sorted by ID, a and b are also in ID order, and
+// counts are the insertion counters of the docs.
+func (t TieBreak) before(counts []int) func(a, b int) bool {
+	switch t {
+	case AscendingID:
+		return func(a, b int) bool { return a > b }
+	case InsertionOrder:
+		return func(a, b int) bool { return counts[a] > counts[b] }
+	}
+
+	return func(a, b int) bool { return a < b }
+}
+
+// setPositions replaces the ranks with the positions
+// of the documents in order. If the policy is KeepTies,
+// the documents for which equal returns true for the
+// previous document share its position.
+func (t TieBreak) setPositions(ranks, order []int, equal func(a, b int) bool) {
+	pos := make([]int, len(order))
+	for j := 1; j < len(order); j++ {
+		if t == KeepTies && equal(order[j-1], order[j]) {
+			pos[j] = pos[j-1]
+		} else {
+			pos[j] = j
+		}
+	}
+
+	for j, i := range order {
+		ranks[i] = pos[j]
+	}
+}
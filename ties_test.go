@@ -0,0 +1,157 @@
+package hyb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieBreak(t *testing.T) {
+	expected := map[TieBreak][]int{
+		DescendingID:   {0, 4, 3, 2, 1},
+		AscendingID:    {0, 1, 2, 3, 4},
+		InsertionOrder: {0, 1, 4, 2, 3},
+		KeepTies:       {0, 1, 2, 3, 4},
+	}
+
+	for ties, ids := range expected {
+		b := NewBuilder()
+		b.SetTieBreak(ties)
+		b.Add(3, []string{"ab"}, 1)
+		b.Add(1, []string{"ab"}, 1)
+		b.Add(4, []string{"ab"}, 1)
+		b.Add(2, []string{"ab"}, 1)
+		b.Add(0, []string{"ab"}, 9)
+
+		// Replacing a document moves it
+		// to the end of the insertion order
+		b.Add(3, []string{"ab"}, 1)
+		idx := b.Build()
+
+		res := &Result{}
+		idx.Search([]string{"a"}, res)
+		assert.Equal(t, ids, hitIDs(res.TopHits(10)), ties.String())
+
+		hits := res.topHits(10, nil)
+		for i := 2; i < len(hits); i++ {
+			if ties == KeepTies {
+				assert.Equal(t, hits[1].rank, hits[i].rank)
+			} else {
+				assert.NotEqual(t, hits[i-1].rank, hits[i].rank)
+			}
+		}
+	}
+}
+
+func TestTieBreakQueryLog(t *testing.T) {
+	b := NewBuilder()
+	b.SetTieBreak(KeepTies)
+	b.AddQuery("cd", 10)
+	b.Add(0, []string{"ab"}, 1)
+	b.Add(1, []string{"ab", "cd"}, 1)
+	b.Add(2, []string{"ab"}, 1)
+	b.Add(3, []string{"ab", "cd"}, 1)
+	idx := b.Build()
+
+	// Documents with the same rank and
+	// popularity keep the same rank
+	res := &Result{}
+	idx.Search([]string{"a"}, res)
+	assert.Equal(t, []int{1, 3, 0, 2}, hitIDs(res.TopHits(10)))
+
+	hits := res.topHits(10, nil)
+	assert.Equal(t, hits[0].rank, hits[1].rank)
+	assert.Equal(t, hits[2].rank, hits[3].rank)
+	assert.NotEqual(t, hits[1].rank, hits[2].rank)
+
+	// Documents with the same blended score
+	// but different ranks do not tie
+	b = NewBuilder()
+	b.SetTieBreak(KeepTies)
+	b.SetQueryWeight(1)
+	b.AddQuery("cd", 10)
+	b.Add(0, []string{"ab"}, 1)
+	b.Add(1, []string{"ab"}, 2)
+	b.Add(2, []string{"ab"}, 2)
+	idx = b.Build()
+
+	res = &Result{}
+	idx.Search([]string{"a"}, res)
+	assert.Equal(t, []int{1, 2, 0}, hitIDs(res.TopHits(10)))
+	assert.Equal(t, 1, idx.maxRank)
+}
+
+func TestTieBreakRecorded(t *testing.T) {
+	build := func(ties TieBreak, ids ...int) *Index {
+		b := NewBuilder()
+		b.SetTieBreak(ties)
+		for _, id := range ids {
+			b.Add(id, []string{"ab"}, 1)
+		}
+		b.Add(100, []string{"ab"}, 0)
+
+		return b.Build()
+	}
+
+	idx := build(KeepTies, 0, 1, 2)
+	assert.Equal(t, KeepTies, idx.Layout().TieBreak)
+	assert.Equal(t, 1, idx.maxRank)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, idx.Write(buf))
+	nidx := NewIndex()
+	assert.Nil(t, nidx.Read(buf))
+	assert.Equal(t, KeepTies, nidx.Layout().TieBreak)
+	assert.Equal(t, 1, nidx.maxRank)
+
+	// Merging keeps the policy and the scale
+	// of the ranks of the merged indexes
+	merged := MergeIndexes(build(KeepTies, 3), nidx, nil)
+	assert.Equal(t, KeepTies, merged.Layout().TieBreak)
+
+	res := &Result{}
+	merged.Search([]string{"a"}, res)
+	assert.Equal(t, []int{0, 1, 2, 3, 100}, hitIDs(res.TopHits(10)))
+
+	hits := res.topHits(10, nil)
+	for _, h := range hits[1:4] {
+		assert.Equal(t, hits[0].rank, h.rank)
+	}
+	assert.True(t, hits[3].rank > hits[4].rank)
+
+	merged = MergeIndexes(build(InsertionOrder, 3), build(InsertionOrder, 2, 0, 1), nil)
+	assert.Equal(t, InsertionOrder, merged.Layout().TieBreak)
+
+	res = &Result{}
+	merged.Search([]string{"a"}, res)
+	assert.Equal(t, []int{3, 2, 0, 1, 100}, hitIDs(res.TopHits(10)))
+}
+
+func TestTieBreakSetOptions(t *testing.T) {
+	layout := BuilderOptions{NumBlocks: 2, ChunkSize: 128}
+
+	// The policy is kept in either order
+	b := NewBuilder()
+	b.SetTieBreak(KeepTies)
+	b.SetOptions(layout)
+	b.Add(0, []string{"ab"}, 1)
+	idx := b.Build()
+	assert.Equal(t, KeepTies, idx.Layout().TieBreak)
+	assert.Equal(t, 2, idx.Layout().NumBlocks)
+
+	b = NewBuilder()
+	b.SetOptions(layout)
+	b.SetTieBreak(KeepTies)
+	b.Add(0, []string{"ab"}, 1)
+	idx = b.Build()
+	assert.Equal(t, KeepTies, idx.Layout().TieBreak)
+	assert.Equal(t, 2, idx.Layout().NumBlocks)
+
+	// A policy in the options replaces it
+	layout.TieBreak = AscendingID
+	b.SetOptions(layout)
+	b.Add(0, []string{"ab"}, 1)
+	idx = b.Build()
+	assert.Equal(t, AscendingID, idx.Layout().TieBreak)
+}
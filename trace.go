@@ -0,0 +1,112 @@
+package hyb
+
+import "time"
+
+// SearchTrace records what happened inside a search. It
+// can be printed as JSON, for example, to find out why a
+// query is slow or why it gives surprising hits.
+type SearchTrace struct {
+	Query []string   `json:"query"`
+	Path  SearchPath `json:"path"`
+
+	// Words contains a trace for each query word that
+	// was searched. It is empty if the result was
+	// restored or precomputed.
+	Words []WordTrace `json:"words"`
+
+	// Results is the number of postings of the result.
+	Results int `json:"results"`
+
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// WordTrace records the search of a query word.
+type WordTrace struct {
+	Word string `json:"word"`
+
+	// Filtered is true if the word extends the previous
+	// word, so the previous result was only filtered by
+	// the word range. Cached is true if the result was
+	// taken from the search cache.
+	Filtered bool `json:"filtered"`
+	Cached   bool `json:"cached"`
+
+	// WordRange is the range of the IDs of the
+	// words that complete the query word. It is
+	// nil if there is no such word.
+	WordRange *[2]uint32 `json:"word_range"`
+
+	// Blocks contains the blocks that may
+	// have the completions of the word.
+	Blocks []BlockTrace `json:"blocks"`
+
+	// Estimate is the estimated number of postings
+	// given by the character frequencies and Results
+	// is the actual number of postings.
+	Estimate int `json:"estimate"`
+	Results  int `json:"results"`
+
+	// The time spent selecting the blocks, finding the
+	// word range, intersecting the blocks and merging
+	// their postings.
+	SelectTime    time.Duration `json:"select_ns"`
+	WordRangeTime time.Duration `json:"word_range_ns"`
+	IntersectTime time.Duration `json:"intersect_ns"`
+	MergeTime     time.Duration `json:"merge_ns"`
+}
+
+// BlockTrace records the intersection of a block.
+type BlockTrace struct {
+	Block int `json:"block"`
+
+	// Chunks is the number of chunks of the block.
+	// Unpacked chunks were decompressed and the rest
+	// were skipped since their IDs are not in the
+	// previous result.
+	Chunks   int `json:"chunks"`
+	Unpacked int `json:"unpacked"`
+	Skipped  int `json:"skipped"`
+
+	// Scanned is the number of postings unpacked and
+	// Matches is the number of those that matched.
+	Scanned int `json:"scanned"`
+	Matches int `json:"matches"`
+
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// SetTrace enables or disables the tracing of the
+// searches of the result. Tracing is disabled by
+// default since it slows down searching.
+func (r *Result) SetTrace(enabled bool) {
+	r.tracing = enabled
+	if !enabled {
+		r.trace = nil
+	}
+}
+
+// Trace returns the trace of the last search.
+// It returns nil if tracing is disabled.
+func (r *Result) Trace() *SearchTrace {
+	return r.trace
+}
+
+// MarshalText implements encoding.TextMarshaler
+// so that a path is printed as its name.
+func (p SearchPath) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// finish records the outcome of the search of r
+// that started at the given time.
+func (t *SearchTrace) finish(r *Result, start time.Time) {
+	t.Path = r.path
+	t.Results = len(r.results)
+	t.Duration = time.Since(start)
+}
+
+// word adds the trace of a query word.
+func (t *SearchTrace) word(w string) *WordTrace {
+	t.Words = append(t.Words, WordTrace{Word: w})
+	return &t.Words[len(t.Words)-1]
+}
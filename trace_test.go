@@ -0,0 +1,99 @@
+package hyb
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTrace(t *testing.T) {
+	_, docs := createIndex("files/books.txt.gz")
+
+	b := NewBuilder()
+	b.SetOptions(BuilderOptions{NumBlocks: 20, ChunkSize: 128})
+	for i, d := range docs {
+		b.Add(i, strings.Fields(d), len(docs)-i)
+	}
+	index := b.Build()
+
+	res := &Result{}
+	assert.Nil(t, res.Trace())
+	res.SetTrace(true)
+
+	index.Search([]string{"the"}, res)
+	tr := res.Trace()
+	assert.Equal(t, FullSearch, tr.Path)
+	assert.Equal(t, []string{"the"}, tr.Query)
+	assert.Equal(t, len(res.results), tr.Results)
+	assert.Len(t, tr.Words, 1)
+
+	w := tr.Words[0]
+	assert.False(t, w.Filtered)
+	assert.NotNil(t, w.WordRange)
+	assert.NotEmpty(t, w.Blocks)
+	assert.True(t, w.Estimate > 0)
+	assert.Equal(t, tr.Results, w.Results)
+
+	scanned := 0
+	for _, bt := range w.Blocks {
+		assert.Equal(t, bt.Chunks, bt.Unpacked)
+		assert.Equal(t, 0, bt.Skipped)
+		scanned += bt.Scanned
+	}
+	assert.Equal(t, res.scanned, scanned)
+
+	// Intersecting with a small previous
+	// result skips most of the chunks
+	rare, other := "", ""
+	for _, d := range docs {
+		f := strings.Fields(d)
+		if len(f) > 1 && len(f[0]) >= 8 && len(f[1]) >= 2 {
+			rare, other = f[0], f[1]
+			break
+		}
+	}
+	index.Search([]string{rare}, res)
+	index.Search([]string{rare, other[:1]}, res)
+	tr = res.Trace()
+	assert.Equal(t, Continuation, tr.Path)
+	assert.Len(t, tr.Words, 1)
+
+	skipped := 0
+	for _, bt := range tr.Words[0].Blocks {
+		assert.Equal(t, bt.Chunks, bt.Unpacked+bt.Skipped)
+		skipped += bt.Skipped
+	}
+	assert.True(t, skipped > 0)
+
+	// Extending the last word only filters
+	index.Search([]string{rare, other[:2]}, res)
+	tr = res.Trace()
+	assert.True(t, tr.Words[0].Filtered)
+	assert.Empty(t, tr.Words[0].Blocks)
+	assert.Equal(t, 0, res.scanned)
+
+	data, err := json.Marshal(tr)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"path":"continuation"`)
+	assert.Contains(t, string(data), `"filtered":true`)
+
+	res.SetTrace(false)
+	index.Search([]string{"a"}, res)
+	assert.Nil(t, res.Trace())
+}
+
+func TestSearchTraceEmpty(t *testing.T) {
+	index := NewBuilder().Build()
+
+	res := &Result{}
+	res.SetTrace(true)
+	index.Search([]string{"a"}, res)
+	assert.Equal(t, 0, res.Hits().Len())
+
+	tr := res.Trace()
+	assert.Len(t, tr.Words, 1)
+	assert.Equal(t, 0, tr.Words[0].Estimate)
+	assert.Empty(t, tr.Words[0].Blocks)
+}
@@ -0,0 +1,69 @@
+package hyb
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tracer starts the spans of the phases of a search. It
+// mirrors the tracer of OpenTelemetry so that an adapter
+// is only a few lines, without making this package depend
+// on it. The hybtrace package has an in-memory tracer for
+// tests. It must be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span that is a child of the span
+	// in ctx, if any, and returns a context with it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	End()
+}
+
+// Attribute is a key and value pair that describes
+// a span. The value is an int, a bool or a string.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// SetTracer sets the tracer of the searches. Search
+// emits a span for the search of each query word, and
+// in it, spans for the block selection, getWordRange,
+// the intersection of each block and the merge. Passing
+// nil disables tracing, which is the default. It must
+// be called before searching the index.
+func (idx *Index) SetTracer(t Tracer) {
+	idx.tracer = t
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) End()                             {}
+
+// startSpan starts a span if the index has a tracer.
+func (idx *Index) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if idx.tracer == nil {
+		return ctx, noopSpan{}
+	}
+
+	return idx.tracer.Start(ctx, name)
+}
+
+// endSearch ends the span of the search of a query.
+func (idx *Index) endSearch(span Span, query []string, r *Result) {
+	if idx.tracer != nil {
+		span.SetAttributes(
+			Attribute{"hyb.query.words", len(query)},
+			Attribute{"hyb.query.length", utf8.RuneCountInString(strings.Join(query, " "))},
+			Attribute{"hyb.path", r.path.String()},
+			Attribute{"hyb.postings.scanned", r.scanned},
+			Attribute{"hyb.results", len(r.results)},
+		)
+	}
+	span.End()
+}